@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Accounts supplied with the -account flag, indexed by their alias.
+// Each is either the name of a profile or the ARN of a role to assume with the credentials of the default one.
+// Ex.: dynsubst -account billing=arn:aws:iam::123456789012:role/dynsubst app-settings
+type accountFlag map[string]string
+
+func (a accountFlag) String() string {
+	var accounts []string
+	for alias, account := range a {
+		accounts = append(accounts, fmt.Sprintf("%s=%s", alias, account))
+	}
+	sort.Strings(accounts)
+
+	return strings.Join(accounts, ",")
+}
+
+func (a accountFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected \"alias=profile\" or \"alias=role-arn\"")
+	}
+	alias, account := value[:i], value[i+1:]
+	if _, ok := a[alias]; ok {
+		return fmt.Errorf("account already specified for alias \"%s\"", alias)
+	}
+	a[alias] = account
+
+	return nil
+}
+
+var (
+	accounts = accountFlag{}
+	// Sessions created for every account used so far, indexed by their alias.
+	accountSessions = map[string]*session.Session{}
+	// Alias of the account used by the placeholder being resolved, if any.
+	currentAccount string
+)
+
+// Switches to the session of the account with the alias until the returned function is called.
+func useAccount(alias string) (func(), error) {
+	s, ok := accountSessions[alias]
+	if !ok {
+		account := accounts[alias]
+		if strings.HasPrefix(account, "arn:") {
+			creds := stscreds.NewCredentials(sess, account)
+			s = sess.Copy(aws.NewConfig().WithCredentials(creds))
+		} else {
+			awsConfig, err := newConfig()
+			if err != nil {
+				return nil, err
+			}
+			s, err = session.NewSessionWithOptions(session.Options{
+				Config:                  *awsConfig,
+				Profile:                 account,
+				SharedConfigState:       session.SharedConfigEnable,
+				AssumeRoleTokenProvider: mfaTokenProvider,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error creating session for account \"%s\": %w", alias, err)
+			}
+			addHandlers(s)
+		}
+		accountSessions[alias] = s
+	}
+
+	savedSess, savedAccount := sess, currentAccount
+	sess, currentAccount = s, alias
+	return func() {
+		sess, currentAccount = savedSess, savedAccount
+	}, nil
+}
+
+// Returns the key under which information about the table is cached,
+// as tables with the same name in different accounts or regions are different tables.
+func cacheKey(table string) string {
+	if currentAccount == "" && currentRegion == "" {
+		return table
+	}
+	return currentAccount + ":" + currentRegion + ":" + table
+}
+
+// Returns the profile providing the credentials of the current session,
+// which is the one of the account in use when it is mapped to a profile rather than to a role.
+func currentProfile() string {
+	if account := accounts[currentAccount]; currentAccount != "" && !strings.HasPrefix(account, "arn:") {
+		return account
+	}
+	return profile
+}
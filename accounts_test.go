@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestCurrentProfile(t *testing.T) {
+	savedProfile, savedAccount := profile, currentAccount
+	defer func() { profile, currentAccount = savedProfile, savedAccount }()
+	accounts["test-billing"] = "billing-sso"
+	accounts["test-role"] = "arn:aws:iam::123456789012:role/dynsubst"
+	defer delete(accounts, "test-billing")
+	defer delete(accounts, "test-role")
+
+	profile = "default"
+	tests := []struct {
+		account, want string
+	}{
+		{"", "default"},
+		{"test-billing", "billing-sso"},
+		{"test-role", "default"},
+	}
+	for _, tt := range tests {
+		currentAccount = tt.account
+		if got := currentProfile(); got != tt.want {
+			t.Errorf("account %q: got %q, want %q", tt.account, got, tt.want)
+		}
+	}
+}
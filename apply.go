@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v3"
+)
+
+// A file to render, as listed in the manifest supplied to the apply command.
+type applyEntry struct {
+	// Table used by placeholders without one, defaulting to the one supplied with -table.
+	Table string `yaml:"table"`
+	// Template to render, relative to the manifest.
+	Template string `yaml:"template"`
+	// File to write the rendered template to.
+	Destination string `yaml:"destination"`
+	// Permissions of the file in octal, defaulting to those of an existing file or the template.
+	Mode string `yaml:"mode"`
+	// Owner of the file as "user[:group]", by name or ID, left unchanged when empty.
+	Owner string `yaml:"owner"`
+	// Whether to compress the file with gzip.
+	Gzip bool `yaml:"gzip"`
+	// Shell command validating the file, such as "nginx -t", run once it is written and before
+	// any file is written when it contains "%s", which is replaced with the path of the temporary file.
+	// Every file is restored to its previous contents if it fails.
+	Validate string `yaml:"validate"`
+	// Shell command run once the file is written, only if its contents changed, such as "systemctl reload nginx".
+	ExecOnChange string `yaml:"exec-on-change"`
+}
+
+// A rendered file waiting to be written.
+type applyResult struct {
+	name     string
+	output   []byte
+	perm     os.FileMode
+	uid, gid int
+	validate string
+	// Command run if the file changed.
+	execOnChange string
+	// Whether the file is already stamped as up to date with -stamp.
+	upToDate bool
+}
+
+// Contents of a file before being overwritten, to restore it if the files cannot all be committed.
+type backup struct {
+	name     string
+	data     []byte
+	perm     os.FileMode
+	uid, gid int
+	exists   bool
+}
+
+// Renders every file listed in the manifest, and only writes them once all of them are rendered,
+// so that either every file is updated or none of them are.
+// Files are first written to temporary files next to them, which are then renamed over the destinations.
+// The manifest is a YAML list of entries such as
+// {table: app-settings, template: nginx.conf.tmpl, destination: /etc/nginx/nginx.conf, mode: "0640", owner: "root:nginx"},
+// optionally compressed with "gzip: true" and checked with a "validate" command.
+// Ex.: dynsubst apply files.yaml
+func runApply(args []string) {
+	if len(args) != 1 {
+		log.Fatal("apply: usage: apply manifest")
+	}
+	entries, err := loadManifest(args[0])
+	if err != nil {
+		log.Fatalf("apply: %v", err)
+	}
+
+	var results []applyResult
+	for _, entry := range entries {
+		table = entry.Table
+		result, err := renderEntry(entry)
+		if err != nil {
+			log.Fatalf("apply: %s: %v", entry.Template, withSuggestions(err))
+		}
+		results = append(results, result)
+	}
+
+	changed, err := commitFiles(results)
+	if err != nil {
+		log.Fatalf("apply: %v", err)
+	}
+	// Commands are run once each, even if several files changed.
+	commands := map[string]bool{}
+	for _, result := range changed {
+		for _, command := range []string{result.execOnChange, execOnChange} {
+			if command != "" && !commands[command] {
+				commands[command] = true
+				if err := runOnChange(command); err != nil {
+					log.Fatalf("apply: %v", err)
+				}
+			}
+		}
+	}
+	if err := saveCache(); err != nil {
+		log.Printf("apply: warning: error saving cache: %v", err)
+	}
+}
+
+// Returns the entries of the manifest, with templates relative to it and
+// the table supplied with -table for those without one.
+func loadManifest(file string) ([]applyEntry, error) {
+	input, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entries []applyEntry
+	if err := yaml.Unmarshal(input, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Template == "" || entry.Destination == "" {
+			return nil, fmt.Errorf("%s: entry %d: template and destination are required", file, i+1)
+		}
+		if !filepath.IsAbs(entry.Template) {
+			entry.Template = filepath.Join(filepath.Dir(file), entry.Template)
+		}
+		if entry.Table == "" {
+			entry.Table = tables[""]
+		}
+	}
+
+	return entries, nil
+}
+
+// Renders the template of an entry of the manifest.
+func renderEntry(entry applyEntry) (applyResult, error) {
+	result := applyResult{name: entry.Destination, uid: -1, gid: -1, validate: entry.Validate, execOnChange: entry.ExecOnChange}
+	if target, err := filepath.EvalSymlinks(result.name); err == nil {
+		result.name = target
+	}
+
+	info, err := os.Stat(entry.Template)
+	if err != nil {
+		return result, err
+	}
+	result.perm = info.Mode().Perm()
+	if info, err := os.Stat(result.name); err == nil {
+		result.perm = info.Mode().Perm()
+	}
+	if entry.Mode != "" {
+		mode, err := strconv.ParseUint(entry.Mode, 8, 32)
+		if err != nil {
+			return result, fmt.Errorf("invalid mode \"%s\"", entry.Mode)
+		}
+		result.perm = os.FileMode(mode).Perm()
+	}
+	if entry.Owner != "" {
+		result.uid, result.gid, err = lookupOwner(entry.Owner)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	input, err := ioutil.ReadFile(entry.Template)
+	if err != nil {
+		return result, err
+	}
+	defer saveDirectives()()
+	text, err := applyDirectives(entry.Template, string(input))
+	if err != nil {
+		return result, err
+	}
+	if err := validateTemplate(text); err != nil {
+		return result, err
+	}
+	templateDir = filepath.Dir(entry.Template)
+	end := startSpan("render", attribute.String("file", entry.Template))
+	output, err := renderTemplate(text)
+	end(err)
+	if err != nil {
+		return result, err
+	}
+	output, result.upToDate = stampOutput(result.name, text, output)
+	result.output = []byte(output)
+	if entry.Gzip {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		w.Write(result.output)
+		if err := w.Close(); err != nil {
+			return result, err
+		}
+		result.output = b.Bytes()
+	}
+
+	return result, nil
+}
+
+// Returns the user and group IDs of an owner specified as "user[:group]", by name or ID.
+// The group is left unchanged when not specified.
+func lookupOwner(owner string) (int, int, error) {
+	parts := strings.SplitN(owner, ":", 2)
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		u, err := user.Lookup(parts[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+	}
+	if len(parts) == 1 {
+		return uid, -1, nil
+	}
+	gid, err := strconv.Atoi(parts[1])
+	if err != nil {
+		g, err := user.LookupGroup(parts[1])
+		if err != nil {
+			return 0, 0, err
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+
+	return uid, gid, nil
+}
+
+// Writes the rendered files to temporary files and renames them over their destinations once all are written.
+// Temporary files are removed if any of them cannot be written.
+// Files already containing their rendered template are not written again, only updating their mode and owner.
+// Returns the files written.
+func commitFiles(results []applyResult) ([]applyResult, error) {
+	writing.Lock()
+	defer writing.Unlock()
+
+	var changed, kept []applyResult
+	for _, result := range results {
+		if result.upToDate || unchanged(result.name, result.output) {
+			kept = append(kept, result)
+		} else {
+			changed = append(changed, result)
+		}
+	}
+	results = changed
+
+	var temps []string
+	removeTemps := func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}
+	for _, result := range results {
+		temp, err := writeTemp(result.name, result.output, result.perm, result.uid, result.gid)
+		if err != nil {
+			removeTemps()
+			return nil, fmt.Errorf("%s: %w", result.name, err)
+		}
+		temps = append(temps, temp)
+	}
+	for i, result := range results {
+		if strings.Contains(result.validate, "%s") {
+			if err := validateFile(result.validate, temps[i]); err != nil {
+				removeTemps()
+				return nil, fmt.Errorf("%s: %w", result.name, err)
+			}
+		}
+	}
+
+	var backups []backup
+	for _, result := range results {
+		b := backup{name: result.name}
+		if info, err := os.Stat(result.name); err == nil {
+			b.data, err = ioutil.ReadFile(result.name)
+			if err != nil {
+				removeTemps()
+				return nil, err
+			}
+			b.perm, b.exists = info.Mode().Perm(), true
+			b.uid, b.gid = fileOwner(info)
+		}
+		backups = append(backups, b)
+	}
+	for i, temp := range temps {
+		if err := os.Rename(temp, results[i].name); err != nil {
+			temps = temps[i:]
+			removeTemps()
+			if restoreErr := restoreBackups(backups[:i]); restoreErr != nil {
+				return nil, fmt.Errorf("%s: %w (restoring previous files: %v)", results[i].name, err, restoreErr)
+			}
+			return nil, fmt.Errorf("%s: %w (previous files restored)", results[i].name, err)
+		}
+	}
+
+	// Commands validating written files are run once each, as they often check every file of a service.
+	validated := map[string]bool{}
+	for _, result := range results {
+		if result.validate == "" || strings.Contains(result.validate, "%s") || validated[result.validate] {
+			continue
+		}
+		validated[result.validate] = true
+		if err := validateFile(result.validate, ""); err != nil {
+			if restoreErr := restoreBackups(backups); restoreErr != nil {
+				return nil, fmt.Errorf("%s: %w (restoring previous files: %v)", result.name, err, restoreErr)
+			}
+			return nil, fmt.Errorf("%s: %w (previous files restored)", result.name, err)
+		}
+	}
+	for _, result := range kept {
+		if err := os.Chmod(result.name, result.perm); err != nil {
+			return nil, fmt.Errorf("%s: %w", result.name, err)
+		}
+		if result.uid != -1 || result.gid != -1 {
+			if err := os.Chown(result.name, result.uid, result.gid); err != nil {
+				return nil, fmt.Errorf("%s: %w", result.name, err)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Runs the shell command validating a file, replacing "%s" with the quoted path of the file if any.
+func validateFile(command, name string) error {
+	if name != "" {
+		quoted, _ := shellQuote(name, "")
+		command = strings.ReplaceAll(command, "%s", quoted)
+	}
+	output, err := exec.Command("sh", "-c", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("validation with \"%s\" failed: %v: %s", command, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// Restores the files to their contents, permissions and owners before being overwritten, removing those which did not exist.
+func restoreBackups(backups []backup) error {
+	for _, b := range backups {
+		if !b.exists {
+			if err := os.Remove(b.name); err != nil {
+				return err
+			}
+			continue
+		}
+		temp, err := writeTemp(b.name, b.data, b.perm, b.uid, b.gid)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(temp, b.name); err != nil {
+			os.Remove(temp)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	defer func(saved tableFlag) { tables = saved }(tables)
+	tables = tableFlag{"": "default-settings"}
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "files.yaml")
+	input := `
+- template: app.conf.tmpl
+  destination: /etc/app/app.conf
+- table: network-settings
+  template: /srv/templates/net.conf.tmpl
+  destination: /etc/app/net.conf
+`
+	if err := ioutil.WriteFile(manifest, []byte(input), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := loadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		table, template string
+	}{
+		{"default-settings", filepath.Join(dir, "app.conf.tmpl")},
+		{"network-settings", "/srv/templates/net.conf.tmpl"},
+	}
+	if len(entries) != len(tests) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(tests))
+	}
+	for i, tt := range tests {
+		if entries[i].Table != tt.table {
+			t.Errorf("entry %d: got table %q, want %q", i+1, entries[i].Table, tt.table)
+		}
+		if entries[i].Template != tt.template {
+			t.Errorf("entry %d: got template %q, want %q", i+1, entries[i].Template, tt.template)
+		}
+	}
+}
+
+func TestLoadManifestMissingDestination(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "files.yaml")
+	if err := ioutil.WriteFile(manifest, []byte("- template: app.conf.tmpl\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadManifest(manifest); err == nil {
+		t.Error("got no error for an entry without destination")
+	}
+}
+
+func TestRenderEntryStampedGzip(t *testing.T) {
+	setValues(t, map[string]string{"Host": "db.example.com"})
+	setFlag(t, "stamp", "#")
+
+	dir := t.TempDir()
+	entry := applyEntry{
+		Table:       table,
+		Template:    filepath.Join(dir, "app.conf.tmpl"),
+		Destination: filepath.Join(dir, "app.conf.gz"),
+		Gzip:        true,
+	}
+	if err := ioutil.WriteFile(entry.Template, []byte("host={{Host}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := renderEntry(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.upToDate {
+		t.Fatal("got a file up to date before writing it")
+	}
+	if err := ioutil.WriteFile(entry.Destination, result.output, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = renderEntry(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.upToDate {
+		t.Error("got a compressed file stamped with the same hash out of date")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A combination of flags under which the synthesized template is rendered.
+type benchSetting struct {
+	name string
+	// Whether keys are prefetched in transactions of the size specified.
+	prefetch     bool
+	transactSize int
+	// Value of -scan-threshold.
+	scanThreshold int
+}
+
+// Synthesizes a template referencing keys of the table and measures how fast it is rendered
+// when looking keys up one by one, when prefetching them in transactions of each size
+// and when scanning the table with each -scan-threshold, to help choosing flags for a workload.
+// Placeholders are always resolved one after the other, as dynsubst does not resolve them concurrently.
+// Ex.: dynsubst bench app-settings -placeholders 1000 -unique 200 -transact-sizes 25,100 -scan-thresholds 100
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	count := fs.Int("placeholders", 1000, "amount of placeholders in the template")
+	unique := fs.Int("unique", 200, "amount of distinct keys referenced by the placeholders")
+	sizeList := fs.String("transact-sizes", "10,25,100", "comma-separated amounts of keys prefetched in each transaction")
+	thresholdList := fs.String("scan-thresholds", "1", "comma-separated values of -scan-threshold, scanning the table when more keys are referenced")
+	if len(args) < 1 {
+		log.Fatal("bench: missing table")
+	}
+	table = args[0]
+	fs.Parse(args[1:])
+	if *count < 1 || *unique < 1 {
+		log.Fatal("bench: -placeholders and -unique must be positive")
+	}
+	sizes, err := parseBenchList(*sizeList, maxTransactItems)
+	if err != nil {
+		log.Fatalf("bench: -transact-sizes: %v", err)
+	}
+	thresholds, err := parseBenchList(*thresholdList, 0)
+	if err != nil {
+		log.Fatalf("bench: -scan-thresholds: %v", err)
+	}
+
+	settings := []benchSetting{{name: "lookups"}}
+	for _, size := range sizes {
+		settings = append(settings, benchSetting{name: fmt.Sprintf("prefetch transact-size=%d", size), prefetch: true, transactSize: size})
+	}
+	for _, threshold := range thresholds {
+		settings = append(settings, benchSetting{name: fmt.Sprintf("scan-threshold=%d", threshold), scanThreshold: threshold})
+	}
+
+	// Existing keys are referenced, as lookups of missing ones fail.
+	keys, err := listKeys(table)
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+	if len(keys) == 0 {
+		log.Fatalf("bench: no keys in \"%s\"", table)
+	}
+	if *unique > len(keys) {
+		log.Printf("bench: warning: only %d keys in \"%s\"", len(keys), table)
+		*unique = len(keys)
+	}
+
+	var b strings.Builder
+	for i := 0; i < *count; i++ {
+		fmt.Fprintf(&b, "{{GET:%s}}\n", strings.TrimPrefix(keys[i%*unique], prefix))
+	}
+	text := b.String()
+
+	fmt.Printf("%d placeholders, %d distinct keys\n", *count, *unique)
+	defer func(mode bool, size, threshold int) {
+		prefetchMode, transactSize, scanThreshold = mode, size, threshold
+	}(prefetchMode, transactSize, scanThreshold)
+	for _, s := range settings {
+		snapshot = map[string]map[string]*string{}
+		stats = runStats{}
+		prefetchMode, transactSize, scanThreshold = s.prefetch, s.transactSize, s.scanThreshold
+
+		start := time.Now()
+		if prefetchMode || scanThreshold > 0 {
+			if err := prefetch(text); err != nil {
+				log.Fatalf("bench: %v", err)
+			}
+		}
+		if _, err := render(text); err != nil {
+			log.Fatalf("bench: %v", err)
+		}
+		elapsed := time.Since(start)
+
+		fmt.Printf("%-28s %10v %10.0f placeholders/s %6d AWS requests %8g read capacity units\n",
+			s.name, elapsed.Round(time.Millisecond), float64(*count)/elapsed.Seconds(), stats.requests, stats.capacity)
+	}
+}
+
+// Returns the positive integers of a comma-separated list, which cannot exceed the maximum unless it is zero.
+func parseBenchList(list string, max int) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || (max > 0 && n > max) {
+			if max > 0 {
+				return nil, fmt.Errorf("invalid value \"%s\": expected an integer between 1 and %d", field, max)
+			}
+			return nil, fmt.Errorf("invalid value \"%s\": expected a positive integer", field)
+		}
+		values = append(values, n)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBenchList(t *testing.T) {
+	tests := []struct {
+		list    string
+		max     int
+		want    []int
+		wantErr bool
+	}{
+		{"10,25,100", maxTransactItems, []int{10, 25, 100}, false},
+		{" 5 , 50 ", 0, []int{5, 50}, false},
+		{"", maxTransactItems, nil, false},
+		{"101", maxTransactItems, nil, true},
+		{"1000", 0, []int{1000}, false},
+		{"0", 0, nil, true},
+		{"ten", 0, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBenchList(tt.list, tt.max)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.list, err, tt.wantErr)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
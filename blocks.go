@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Include the section when the key exists and its value is neither empty nor "false".
+	// Ex.: "{{#IF FeatureX}}feature_x = on{{/IF}}"
+	blockIf = "IF"
+	// Include the section when the value of the key is equal to the one specified.
+	// Ex.: "{{#IFEQ Environment prod}}debug = false{{/IF}}"
+	blockIfEq = "IFEQ"
+	// Repeat the section for every item whose key matches the pattern.
+	// Inside the section, "{{.Key}}" and "{{.Value}}" refer to the current item.
+	// Ex.: "{{#EACH app/hosts/*}}server {{.Value}};{{/EACH}}"
+	blockEach = "EACH"
+)
+
+// An item of an AWS DynamoDB table.
+type item struct {
+	Key, Value string
+}
+
+var (
+	// Matches the opening ("{{#NAME args}}") and closing ("{{/NAME}}") tags of blocks.
+	blockRe = regexp.MustCompile(`{{(?:#(\w+)\s+(.+?)|/(\w+))}}`)
+	// Item being iterated over by the innermost EACH block.
+	current *item
+)
+
+// Returns the expansion of the block whose opening tag is located at loc,
+// along with the text following its closing tag.
+// The body of the block is rendered with renderBody.
+func renderBlock(text string, loc []int, renderBody func(string) (string, error)) (output, rest string, err error) {
+	if loc[2] < 0 {
+		return "", "", fmt.Errorf("unexpected %s", text[loc[0]:loc[1]])
+	}
+	name := text[loc[2]:loc[3]]
+	args := text[loc[4]:loc[5]]
+
+	start, end, err := blockEnd(text, loc[1], name)
+	if err != nil {
+		return "", "", err
+	}
+
+	output, err = expandBlock(name, args, text[loc[1]:start], renderBody)
+	if err != nil {
+		return "", "", err
+	}
+
+	return output, text[end:], nil
+}
+
+// Returns the position of the closing tag matching a block opened right before offset.
+func blockEnd(text string, offset int, name string) (start, end int, err error) {
+	depth := 1
+	for _, loc := range blockRe.FindAllStringSubmatchIndex(text[offset:], -1) {
+		if loc[2] >= 0 {
+			depth++
+			continue
+		}
+		depth--
+		if depth == 0 {
+			closing := text[offset+loc[6] : offset+loc[7]]
+			if !sameBlock(name, closing) {
+				return 0, 0, fmt.Errorf("block %s closed by {{/%s}}", name, closing)
+			}
+			return offset + loc[0], offset + loc[1], nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("block %s is not closed", name)
+}
+
+// Reports whether the closing tag matches the block.
+// Every conditional block is closed with "{{/IF}}".
+func sameBlock(name, closing string) bool {
+	if name == blockIfEq {
+		name = blockIf
+	}
+	return name == closing
+}
+
+// Returns the expansion of a single block.
+func expandBlock(name, args, body string, renderBody func(string) (string, error)) (string, error) {
+	switch name {
+	case blockIf:
+		value, found, err := lookup(args)
+		if err != nil {
+			return "", err
+		}
+		if !found || value == "" || value == "false" {
+			return "", nil
+		}
+	case blockIfEq:
+		fields := strings.SplitN(args, " ", 2)
+		if len(fields) != 2 {
+			return "", fmt.Errorf("invalid block {{#%s %s}}: expected key and value", name, args)
+		}
+		value, found, err := lookup(fields[0])
+		if err != nil {
+			return "", err
+		}
+		if !found || value != fields[1] {
+			return "", nil
+		}
+	case blockEach:
+		return expandEach(args, body, renderBody)
+	default:
+		return "", fmt.Errorf("unknown block %s", name)
+	}
+
+	return renderBody(body)
+}
+
+// Returns the body rendered once per item whose key matches the pattern.
+func expandEach(pattern, body string, renderBody func(string) (string, error)) (string, error) {
+	var items []item
+	err := failover(func() (err error) {
+		items, err = dynamodbScan(table, pattern)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	defer func(i *item) { current = i }(current)
+
+	var b strings.Builder
+	for i := range items {
+		current = &items[i]
+		output, err := renderBody(body)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(output)
+	}
+
+	return b.String(), nil
+}
+
+// Returns the value of a field of the current item when the key refers to one.
+func itemField(key string) (string, bool) {
+	if current == nil {
+		return "", false
+	}
+	switch key {
+	case ".Key":
+		return current.Key, true
+	case ".Value":
+		return current.Value, true
+	}
+
+	return "", false
+}
+
+// Returns the value of a key, which accepts the same modifiers as placeholders,
+// and whether it exists.
+func lookup(key string) (string, bool, error) {
+	value, err := resolve(fmt.Sprintf("{{%s}}", key))
+	if errors.Is(err, errNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return value, true, nil
+}
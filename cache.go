@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A value retrieved from AWS DynamoDB and the time at which it was retrieved.
+type cacheEntry struct {
+	Value   string    `json:"value"`
+	Updated time.Time `json:"updated"`
+	// ETag of templates retrieved from URLs.
+	ETag string `json:"etag,omitempty"`
+}
+
+var (
+	// File supplied with -cache, where the last known good values are kept across runs.
+	cacheFile string
+	// Whether to fail instead of using cached values when AWS is unavailable, supplied with -no-stale.
+	noStale bool
+	// Duration during which cached values are used without looking them up again, supplied with -cache-ttl.
+	cacheTTL time.Duration
+	// Values in the cache, indexed by table and key (with its version, if any).
+	cache = map[string]map[string]cacheEntry{}
+	// Whether the cache was modified during the run.
+	cacheModified bool
+)
+
+// Loads the cache from the file supplied with -cache, if it exists.
+func loadCache() error {
+	if cacheFile == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(cacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &cache)
+}
+
+// Saves the cache to the file supplied with -cache if it was modified.
+// The file is only readable by its owner, as values may be sensitive.
+func saveCache() error {
+	if cacheFile == "" || !cacheModified {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+		return err
+	}
+
+	return writeFile(cacheFile, data, 0600)
+}
+
+// Returns the key under which the value of the key with the specified version is cached,
+// which depends on the filter applying to it.
+func cachedKey(key, version string) string {
+	if version != "" {
+		key += "@" + version
+	}
+	if f := activeFilter(); f != "" {
+		key += "?" + f
+	}
+	return key
+}
+
+// Returns the value of the key with the specified version in the table if it was cached within -cache-ttl.
+// Cached values are never used with -verify, as their signatures are not cached along with them.
+func freshValue(table, key, version string) (string, bool) {
+	if cacheFile == "" || cacheTTL <= 0 || verifyKey != "" {
+		return "", false
+	}
+
+	key = cachedKey(key, version)
+	entry, ok := cache[cacheKey(table)][key]
+	if !ok || time.Since(entry.Updated) >= cacheTTL {
+		return "", false
+	}
+	stats.local++
+
+	return entry.Value, true
+}
+
+// Returns the outcome of looking up the key with the specified version in the table.
+// Values retrieved successfully are cached, and cached values are returned instead of regional errors
+// unless running with -no-stale or -verify, so that renders can proceed during outages.
+func cacheResult(table, key, version, value string, err error) (string, error) {
+	if cacheFile == "" {
+		return value, err
+	}
+
+	key = cachedKey(key, version)
+	t := cacheKey(table)
+	if err == nil {
+		if cache[t] == nil {
+			cache[t] = make(map[string]cacheEntry)
+		}
+		if entry, ok := cache[t][key]; !ok || entry.Value != value {
+			cacheModified = true
+		}
+		cache[t][key] = cacheEntry{Value: value, Updated: time.Now().UTC()}
+		return value, nil
+	}
+
+	entry, ok := cache[t][key]
+	if !ok || noStale || verifyKey != "" || !regionalError(err) {
+		return value, err
+	}
+	log.Printf("WARNING: %v: using cached value of \"%s\" from %s", err, key, entry.Updated.Format(time.RFC3339))
+	stats.stale++
+
+	return entry.Value, nil
+}
+
+// Pseudo-table under which decrypted values are cached.
+// It cannot clash with actual tables, whose names cannot contain "@".
+const cacheDecryptedTable = "@decrypted"
+
+// Environment variable containing the base64-encoded 256-bit key encrypting decrypted values in the cache.
+// When not set, a key is generated and stored next to the cache, only readable by its owner.
+const envCacheKey = "DYNSUBST_CACHE_KEY"
+
+var (
+	// Whether to cache decrypted values, supplied with -cache-decrypted.
+	cacheDecrypted bool
+	// Cipher encrypting decrypted values in the cache, once loaded.
+	cacheCipher cipher.AEAD
+)
+
+// Returns the value decrypted with AWS KMS.
+// With -cache-decrypted, decrypted values are cached encrypted, indexed by a digest of their ciphertext,
+// and returned instead of regional errors unless running with -no-stale.
+func decrypt(value string) (string, error) {
+	if cacheFile == "" || !cacheDecrypted {
+		return kmsDecrypt(value)
+	}
+
+	aead, err := loadCacheCipher()
+	if err != nil {
+		return "", fmt.Errorf("error loading cache key: %w", err)
+	}
+	id := fmt.Sprintf("%x", sha256.Sum256([]byte(value)))
+
+	plaintext, err := kmsDecrypt(value)
+	if err == nil {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		if cache[cacheDecryptedTable] == nil {
+			cache[cacheDecryptedTable] = make(map[string]cacheEntry)
+		}
+		sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+		cache[cacheDecryptedTable][id] = cacheEntry{Value: base64.StdEncoding.EncodeToString(sealed), Updated: time.Now().UTC()}
+		cacheModified = true
+		return plaintext, nil
+	}
+
+	entry, ok := cache[cacheDecryptedTable][id]
+	if !ok || noStale || !regionalError(err) {
+		return "", err
+	}
+	sealed, decodeErr := base64.StdEncoding.DecodeString(entry.Value)
+	if decodeErr != nil || len(sealed) < aead.NonceSize() {
+		return "", err
+	}
+	opened, openErr := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if openErr != nil {
+		return "", err
+	}
+	log.Printf("WARNING: %v: using cached decrypted value from %s", err, entry.Updated.Format(time.RFC3339))
+	stats.stale++
+
+	return string(opened), nil
+}
+
+// Returns the cipher encrypting decrypted values in the cache, with the key from the environment
+// or from the key file next to the cache, which is generated if it does not exist.
+func loadCacheCipher() (cipher.AEAD, error) {
+	if cacheCipher != nil {
+		return cacheCipher, nil
+	}
+
+	var key []byte
+	if encoded := os.Getenv(envCacheKey); encoded != "" {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envCacheKey, err)
+		}
+		key = decoded
+	} else {
+		keyFile := cacheFile + ".key"
+		data, err := ioutil.ReadFile(keyFile)
+		switch {
+		case err == nil:
+			key = data
+		case os.IsNotExist(err):
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+				return nil, err
+			}
+			if err := writeFile(keyFile, key, 0600); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expected 256-bit key, got %d bits", len(key)*8)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	cacheCipher, err = cipher.NewGCM(block)
+
+	return cacheCipher, err
+}
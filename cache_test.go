@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestCachedValuesNotVerified(t *testing.T) {
+	savedCache := cache
+	t.Cleanup(func() { cache = savedCache })
+	cache = map[string]map[string]cacheEntry{
+		cacheKey("test-settings"): {"Key": {Value: "cached", Updated: time.Now().UTC()}},
+	}
+	setFlag(t, "cache", "test-cache.json")
+	setFlag(t, "cache-ttl", "1h")
+	outage := awserr.NewRequestFailure(awserr.New("InternalServerError", "unavailable", nil), 500, "")
+
+	if value, ok := freshValue("test-settings", "Key", ""); !ok || value != "cached" {
+		t.Fatalf("got %q and %v without -verify", value, ok)
+	}
+	if value, err := cacheResult("test-settings", "Key", "", "", outage); err != nil || value != "cached" {
+		t.Fatalf("got %q and %v without -verify", value, err)
+	}
+
+	setFlag(t, "verify", "alias/dynsubst")
+	if value, ok := freshValue("test-settings", "Key", ""); ok {
+		t.Errorf("got fresh value %q with -verify", value)
+	}
+	if value, err := cacheResult("test-settings", "Key", "", "", outage); err == nil {
+		t.Errorf("got stale value %q with -verify", value)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Runs the cache subcommand, which inspects and evicts entries of the cache supplied with -cache:
+//
+//	cache ls [table]             lists cached keys and their age, without their values
+//	cache purge [table [key...]] evicts keys, every key of a table or the whole cache
+//	cache stats                  summarizes the cache
+func runCache(args []string) {
+	if cacheFile == "" {
+		log.Fatal("cache: -cache is not set")
+	}
+	if len(args) < 1 {
+		log.Fatal("cache: expected \"ls\", \"purge\" or \"stats\"")
+	}
+
+	switch args[0] {
+	case "ls":
+		if len(args) > 2 {
+			log.Fatal("cache: usage: cache ls [table]")
+		}
+		for _, t := range cachedTables(args[1:]) {
+			var keys []string
+			for key := range cache[t] {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				age := time.Since(cache[t][key].Updated).Round(time.Second)
+				fmt.Printf("%s\t%s\t%v\n", t, key, age)
+			}
+		}
+	case "purge":
+		switch len(args) {
+		case 1:
+			cache = map[string]map[string]cacheEntry{}
+		case 2:
+			delete(cache, args[1])
+		default:
+			for _, key := range args[2:] {
+				delete(cache[args[1]], key)
+			}
+		}
+		cacheModified = true
+		if err := saveCache(); err != nil {
+			log.Fatalf("cache: %v", err)
+		}
+	case "stats":
+		info, err := os.Stat(cacheFile)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("cache: %v", err)
+		}
+		var size int64
+		if info != nil {
+			size = info.Size()
+		}
+		fmt.Printf("%s: %d bytes\n", cacheFile, size)
+		for _, t := range cachedTables(nil) {
+			var oldest, newest time.Time
+			for _, entry := range cache[t] {
+				if oldest.IsZero() || entry.Updated.Before(oldest) {
+					oldest = entry.Updated
+				}
+				if entry.Updated.After(newest) {
+					newest = entry.Updated
+				}
+			}
+			fmt.Printf("%s\t%d keys\toldest %v\tnewest %v\n", t, len(cache[t]),
+				time.Since(oldest).Round(time.Second), time.Since(newest).Round(time.Second))
+		}
+	default:
+		log.Fatalf("cache: unknown command \"%s\"", args[0])
+	}
+}
+
+// Returns the supplied tables, or every cached table if none is supplied, sorted.
+func cachedTables(tables []string) []string {
+	if len(tables) > 0 {
+		return tables
+	}
+	for t := range cache {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+
+	return tables
+}
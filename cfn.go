@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Starts an AWS Lambda handler implementing the CloudFormation custom resource protocol.
+// The resource accepts a "Table" property and a "Keys" property mapping attribute names to keys.
+// Keys accept the same modifiers as placeholders and their values are returned as attributes:
+//
+//	Settings:
+//	  Type: Custom::Dynsubst
+//	  Properties:
+//	    ServiceToken: !GetAtt DynsubstFunction.Arn
+//	    Table: project-settings
+//	    Keys:
+//	      Username: Username
+//	      Password: DECRYPT:Password
+//
+// The values can then be retrieved with "!GetAtt Settings.Password".
+func startCFN() {
+	lambda.Start(cfn.LambdaWrap(cfnHandler))
+}
+
+func cfnHandler(ctx context.Context, event cfn.Event) (string, map[string]interface{}, error) {
+	physicalResourceID := event.PhysicalResourceID
+	if physicalResourceID == "" {
+		physicalResourceID = fmt.Sprintf("%s-%s", event.LogicalResourceID, event.RequestID)
+	}
+
+	// Nothing is created, so there is nothing to clean up on deletion.
+	if event.RequestType == cfn.RequestDelete {
+		return physicalResourceID, nil, nil
+	}
+
+	t, ok := event.ResourceProperties["Table"].(string)
+	if !ok || t == "" {
+		return physicalResourceID, nil, fmt.Errorf("missing \"Table\" property")
+	}
+	keys, ok := event.ResourceProperties["Keys"].(map[string]interface{})
+	if !ok {
+		return physicalResourceID, nil, fmt.Errorf("missing \"Keys\" property")
+	}
+
+	table = t
+	data := make(map[string]interface{}, len(keys))
+	for name, k := range keys {
+		key, ok := k.(string)
+		if !ok {
+			return physicalResourceID, nil, fmt.Errorf("invalid key for \"%s\": %v", name, k)
+		}
+		value, err := resolve(fmt.Sprintf("{{%s}}", key))
+		if err != nil {
+			return physicalResourceID, nil, err
+		}
+		data[name] = value
+	}
+
+	return physicalResourceID, data, nil
+}
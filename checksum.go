@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Attribute holding the hexadecimal SHA-256 digest of the value of an item, verified when present
+// to catch values corrupted or partially written by other tools.
+const checksumAttr = "Checksum"
+
+// Verifies the checksum of the item, if it has one.
+func verifyChecksum(attrs map[string]*dynamodb.AttributeValue) error {
+	checksum := attrs[checksumAttr]
+	if checksum == nil || checksum.S == nil {
+		return nil
+	}
+
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte(aws.StringValue(attrs["Value"].S))))
+	if !strings.EqualFold(digest, *checksum.S) {
+		return fmt.Errorf("refusing value of \"%s\" not matching its checksum", aws.StringValue(attrs["Key"].S))
+	}
+
+	return nil
+}
+
+// Verifies the integrity of the item with its checksum and signature.
+func checkItem(attrs map[string]*dynamodb.AttributeValue) error {
+	if err := verifyChecksum(attrs); err != nil {
+		return err
+	}
+	return verifySignature(attrs)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// Prefix of the lines at the start of a template configuring how it is rendered.
+	// Ex.: "#dynsubst: table=app-settings region=eu-west-1 format=yaml"
+	directivePrefix = "#dynsubst:"
+	// Extension of the files declaring directives for the template sharing their name,
+	// for templates that cannot contain them: "config.json.dynsubst" for "config.json".
+	sidecarExt = ".dynsubst"
+	// Name under which dynsubst renders the template supplied as its only argument,
+	// so that templates starting with "#!/usr/bin/env dynsubst-run" can be executed.
+	runName = "dynsubst-run"
+)
+
+// Flags that can be set from directives, as they only affect how a single template is rendered,
+// indexed by the name of the directive setting them.
+// The table is set directly, as the -table flag accepts aliases.
+var directiveFlags = map[string]string{
+	"region":      "r",
+	"profile":     "p",
+	"prefix":      "prefix",
+	"env-suffix":  "env-suffix",
+	"ignore-case": "ignore-case",
+	"engine":      "engine",
+	"format":      "format",
+	"native":      "native",
+	"envsubst":    "envsubst",
+	"recursive":   "recursive",
+	"max-depth":   "max-depth",
+	"output":      "output",
+}
+
+// Directives that remote templates cannot declare, as they would let whoever serves the template
+// choose where its output is written, how it is evaluated, which credentials are used
+// or expose environment variables and values found in the table.
+var localDirectives = map[string]bool{
+	"output":    true,
+	"engine":    true,
+	"profile":   true,
+	"envsubst":  true,
+	"recursive": true,
+}
+
+// Applies the directives declared for the template read from the file, which take precedence over flags.
+// Directives at the start of the template take precedence over those of its sidecar file.
+// Returns the template without them.
+func applyDirectives(file, text string) (string, error) {
+	var reconnect bool
+	if file != "" && !isRemote(file) {
+		sidecar, err := os.Open(file + sidecarExt)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if err == nil {
+			defer sidecar.Close()
+			scanner := bufio.NewScanner(sidecar)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "#") && !strings.HasPrefix(line, directivePrefix) {
+					continue
+				}
+				r, err := applyDirective(strings.TrimPrefix(line, directivePrefix), false)
+				if err != nil {
+					return "", fmt.Errorf("%s: %w", sidecar.Name(), err)
+				}
+				reconnect = reconnect || r
+			}
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// The interpreter line of executable templates is not part of them.
+	if strings.HasPrefix(text, "#!") {
+		end := strings.IndexByte(text, '\n')
+		if end < 0 {
+			end = len(text)
+		}
+		if strings.Contains(text[:end], runName) {
+			text = strings.TrimPrefix(text[end:], "\n")
+		}
+	}
+
+	for strings.HasPrefix(text, directivePrefix) {
+		line := text
+		if end := strings.IndexByte(text, '\n'); end >= 0 {
+			line, text = text[:end], text[end+1:]
+		} else {
+			text = ""
+		}
+		r, err := applyDirective(strings.TrimPrefix(line, directivePrefix), isRemote(file))
+		if err != nil {
+			return "", err
+		}
+		reconnect = reconnect || r
+	}
+
+	if reconnect {
+		s, err := newSession()
+		if err != nil {
+			return "", err
+		}
+		sess = s
+	}
+
+	return text, nil
+}
+
+// Applies the "name=value" directives separated by whitespace in the line.
+// Directives restricted to local templates are rejected when the template is remote.
+// Reports whether the session must be recreated.
+func applyDirective(line string, remote bool) (bool, error) {
+	var reconnect bool
+	for _, field := range strings.Fields(line) {
+		name, value := field, "true"
+		if i := strings.Index(field, "="); i >= 0 {
+			name, value = field[:i], field[i+1:]
+		}
+		switch {
+		case remote && localDirectives[name]:
+			return false, fmt.Errorf("directive \"%s\" is not allowed in remote templates", name)
+		case name == "table":
+			table = value
+		case directiveFlags[name] != "":
+			if err := flag.Set(directiveFlags[name], value); err != nil {
+				return false, fmt.Errorf("invalid directive \"%s\": %w", field, err)
+			}
+			reconnect = reconnect || name == "region" || name == "profile"
+		default:
+			return false, fmt.Errorf("unknown directive \"%s\"", name)
+		}
+	}
+
+	return reconnect, nil
+}
+
+// Returns a function restoring the table, the session and the flags that can be set from directives,
+// so that the directives of a template do not apply to the ones rendered after it.
+func saveDirectives() func() {
+	savedTable, savedSess := table, sess
+	values := make(map[string]string, len(directiveFlags))
+	for _, name := range directiveFlags {
+		values[name] = flag.Lookup(name).Value.String()
+	}
+
+	return func() {
+		table, sess = savedTable, savedSess
+		for name, value := range values {
+			flag.Set(name, value)
+		}
+	}
+}
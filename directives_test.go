@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyDirectives(t *testing.T) {
+	defer saveDirectives()()
+
+	text, err := applyDirectives("", "#dynsubst: table=app-settings region=eu-west-1 format=yaml\nhost: {{Host}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "host: {{Host}}\n" {
+		t.Errorf("got template %q", text)
+	}
+	if table != "app-settings" || region != "eu-west-1" || format != formatYAML {
+		t.Errorf("got table %q, region %q and format %q", table, region, format)
+	}
+}
+
+func TestApplyDirectivesUnknown(t *testing.T) {
+	defer saveDirectives()()
+
+	if _, err := applyDirectives("", "#dynsubst: colour=blue\n"); err == nil {
+		t.Error("expected an error for an unknown directive")
+	}
+}
+
+func TestApplyDirectivesRemote(t *testing.T) {
+	tests := []struct {
+		name, file, text string
+		wantErr          bool
+	}{
+		{"local output", "config.yaml", "#dynsubst: output=/tmp/config.yaml\n", false},
+		{"remote output", "https://example.com/config.yaml", "#dynsubst: output=/tmp/config.yaml\n", true},
+		{"remote engine", "s3://bucket/config.yaml", "#dynsubst: engine=gotemplate\n", true},
+		{"remote profile", "s3://bucket/config.yaml", "#dynsubst: profile=prod\n", true},
+		{"remote envsubst", "https://example.com/config.yaml", "#dynsubst: envsubst=true\n", true},
+		{"remote recursive", "s3://bucket/config.yaml", "#dynsubst: recursive\n", true},
+		{"remote table", "https://example.com/config.yaml", "#dynsubst: table=app-settings\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer saveDirectives()()
+
+			_, err := applyDirectives(tt.file, tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "remote templates") {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
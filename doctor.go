@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// Amount of items sampled to check their attributes.
+	doctorSamples = 100
+	// Maximum amount of encrypted values sampled to check they can be decrypted.
+	doctorDecrypts = 5
+)
+
+// Leading bytes of ciphertexts produced by AWS KMS with symmetric keys, used to recognize encrypted values.
+var kmsCiphertextHeader = []byte{0x01, 0x02, 0x02, 0x00}
+
+// Stops scanning once enough items are sampled.
+var errEnoughSamples = errors.New("enough samples")
+
+// Checks that the table is set up as dynsubst expects and reports likely misconfigurations,
+// exiting with an error if any would make renders fail.
+// Ex.: dynsubst doctor app-settings
+func runDoctor(args []string) {
+	if len(args) != 1 {
+		log.Fatal("doctor: usage: doctor table")
+	}
+	table = args[0]
+
+	var failed bool
+	report := func(ok bool, format string, a ...interface{}) {
+		status := "ok"
+		if !ok {
+			status, failed = "error", true
+		}
+		fmt.Printf("%-7s %s\n", status+":", fmt.Sprintf(format, a...))
+	}
+	warn := func(format string, a ...interface{}) {
+		fmt.Printf("%-7s %s\n", "warning:", fmt.Sprintf(format, a...))
+	}
+
+	desc, err := describeTable(table)
+	if err != nil {
+		log.Fatalf("doctor: %v", err)
+	}
+	types := make(map[string]string)
+	for _, def := range desc.AttributeDefinitions {
+		types[aws.StringValue(def.AttributeName)] = aws.StringValue(def.AttributeType)
+	}
+	for _, element := range desc.KeySchema {
+		name := aws.StringValue(element.AttributeName)
+		switch aws.StringValue(element.KeyType) {
+		case dynamodb.KeyTypeHash:
+			report(name == "Key" && types[name] == "S", "partition key is %s of type %s (expected Key of type S)", name, types[name])
+		case dynamodb.KeyTypeRange:
+			report(name == versionAttr && types[name] == "N", "sort key is %s of type %s (expected %s of type N for versions)", name, types[name], versionAttr)
+		}
+	}
+
+	if attr := ttlAttribute(table); attr != "" {
+		report(true, "expired items are ignored using the %s attribute", attr)
+	} else {
+		warn("no TTL attribute is configured, so expired items are not ignored")
+	}
+
+	var sampled, missing, nonString, encrypted, undecryptable int
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(table),
+		Limit:     aws.Int64(doctorSamples),
+	}
+	err = scanItems(scanInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		sampled++
+		value := attrs["Value"]
+		switch {
+		case value == nil:
+			missing++
+		case value.S == nil:
+			nonString++
+		default:
+			decoded, err := base64.StdEncoding.DecodeString(*value.S)
+			if err == nil && bytes.HasPrefix(decoded, kmsCiphertextHeader) {
+				encrypted++
+				if encrypted <= doctorDecrypts {
+					if _, err := kmsDecrypt(*value.S); err != nil {
+						undecryptable++
+						warn("cannot decrypt value of %s: %v", aws.StringValue(attrs["Key"].S), err)
+					}
+				}
+			}
+		}
+		if sampled >= doctorSamples {
+			return errEnoughSamples
+		}
+		return nil
+	})
+	if err != nil && err != errEnoughSamples {
+		log.Fatalf("doctor: %v", err)
+	}
+	report(missing == 0, "%d of %d sampled items lack the Value attribute", missing, sampled)
+	report(nonString == 0, "%d of %d sampled items have a Value attribute which is not a string", nonString, sampled)
+	if encrypted > 0 {
+		checked := encrypted
+		if checked > doctorDecrypts {
+			checked = doctorDecrypts
+		}
+		report(undecryptable == 0, "%d of %d checked encrypted values cannot be decrypted", undecryptable, checked)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
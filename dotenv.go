@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches values which can be written in .env files without quotes.
+	dotenvSafeRe = regexp.MustCompile(`^[\w@%+=:,./-]*$`)
+	// Matches characters which cannot be part of the names of variables.
+	dotenvInvalidRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// Prints a .env file, as read by docker-compose, with the values of the keys supplied as arguments
+// or of every key starting with -prefix, so that development environments can be set up from the table.
+// Arguments are either keys, which are used as the names of the variables, or "NAME=key",
+// where keys accept the same modifiers as placeholders. Variables for keys starting with -prefix are
+// named after the rest of the key in upper case, with characters not allowed in names replaced with "_".
+// Ex.: dynsubst dotenv app-settings DB_HOST DB_PASSWORD=DECRYPT:db/password > .env
+func runDotenv(args []string) {
+	fs := flag.NewFlagSet("dotenv", flag.ExitOnError)
+	keyPrefix := fs.String("prefix", "", "include every key starting with the prefix")
+	if len(args) < 1 {
+		log.Fatal("dotenv: usage: dotenv table [-prefix prefix] [[NAME=]key...]")
+	}
+	table = args[0]
+	fs.Parse(args[1:])
+	if *keyPrefix == "" && fs.NArg() == 0 {
+		log.Fatal("dotenv: no keys supplied")
+	}
+
+	var names, keys []string
+	if *keyPrefix != "" {
+		all, err := listKeys(table)
+		if err != nil {
+			log.Fatalf("dotenv: %v", err)
+		}
+		for _, k := range all {
+			if !strings.HasPrefix(k, prefix+*keyPrefix) {
+				continue
+			}
+			k = strings.TrimPrefix(k, prefix)
+			names = append(names, strings.ToUpper(dotenvInvalidRe.ReplaceAllString(strings.TrimPrefix(k, *keyPrefix), "_")))
+			// Keys are retrieved as is, even if they coincidentally contain modifiers.
+			keys = append(keys, modGet+":"+k)
+		}
+	}
+	for _, arg := range fs.Args() {
+		name, key := arg, arg
+		if i := strings.Index(arg, "="); i >= 0 {
+			name, key = arg[:i], arg[i+1:]
+		}
+		names = append(names, name)
+		keys = append(keys, key)
+	}
+
+	var b strings.Builder
+	for i, key := range keys {
+		if names[i] == "" || dotenvInvalidRe.MatchString(names[i]) {
+			log.Fatalf("dotenv: invalid variable name \"%s\"", names[i])
+		}
+		value, err := resolve(fmt.Sprintf("{{%s}}", key))
+		if err != nil {
+			log.Fatalf("dotenv: %v", withSuggestions(err))
+		}
+		fmt.Fprintf(&b, "%s=%s\n", names[i], dotenvQuote(value))
+	}
+	if err := saveCache(); err != nil {
+		log.Printf("dotenv: warning: error saving cache: %v", err)
+	}
+	fmt.Print(b.String())
+}
+
+// Returns the value quoted as needed for docker-compose to read it literally.
+// Values with quotes or line breaks are double-quoted, escaping them along with "$", which starts interpolations.
+func dotenvQuote(value string) string {
+	if dotenvSafeRe.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\r\n") {
+		return "'" + value + "'"
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", "$$").Replace(value) + `"`
+}
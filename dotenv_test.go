@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestDotenvQuote(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"db.example.com", "db.example.com"},
+		{"", ""},
+		{"user@host:5432/db", "user@host:5432/db"},
+		{"two words", "'two words'"},
+		{"$HOME", "'$HOME'"},
+		{"it's", `"it's"`},
+		{"line1\nline2", `"line1\nline2"`},
+		{"it's $5 \"off\"\n", `"it's $$5 \"off\"\n"`},
+		{`C:\path's`, `"C:\\path's"`},
+	}
+	for _, tt := range tests {
+		if got := dotenvQuote(tt.value); got != tt.want {
+			t.Errorf("dotenvQuote(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	// Exit code when the deployed file differs from the rendered template, as with diff.
+	exitDrift = 1
+	// Exit code when the files cannot be compared, as with diff.
+	exitDriftError = 2
+)
+
+// Renders the template and reports whether the deployed file differs from it, and where,
+// so that manual edits or changes to the table since the file was deployed can be detected.
+// Differences are printed as hunks of a unified diff without context, unless running with -q,
+// as they may include secrets. Exits with 0 if the files match, 1 if they differ and 2 on errors.
+// Ex.: dynsubst drift app-settings app.conf.tmpl /etc/app/app.conf
+func runDrift(args []string) {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	quiet := fs.Bool("q", false, "only report whether the files differ")
+	if len(args) < 3 {
+		log.Fatal("drift: usage: drift table template file [-q]")
+	}
+	table = args[0]
+	src, dst := args[1], args[2]
+	fs.Parse(args[3:])
+
+	expected, actual, err := driftOutputs(src, dst)
+	if err != nil {
+		driftFatal(withSuggestions(err))
+	}
+
+	if actual == expected {
+		return
+	}
+	fmt.Printf("%s differs from %s rendered with %s\n", dst, src, table)
+	if !*quiet {
+		fmt.Printf("--- %s\n+++ %s\n", dst, src)
+		fmt.Print(diffLines(strings.SplitAfter(actual, "\n"), strings.SplitAfter(expected, "\n")))
+	}
+	os.Exit(exitDrift)
+}
+
+// Returns the template rendered as it would be written to the file, along with the contents of the file.
+// The output is stamped as with -stamp, and ends with the newline added when printing it to standard output
+// if the file ends with one more newline than the output, as when deployed with "dynsubst table tmpl > file".
+func driftOutputs(src, dst string) (expected, actual string, err error) {
+	r, err := renderSource(src, false)
+	if err != nil {
+		return "", "", err
+	}
+	input, err := ioutil.ReadFile(dst)
+	if err != nil {
+		return "", "", err
+	}
+
+	expected, _ = stampOutput(dst, r.text, r.output)
+	actual = string(input)
+	if trailingNewlines(actual) == trailingNewlines(expected)+1 {
+		expected += "\n"
+	}
+
+	return expected, actual, nil
+}
+
+// Returns the amount of newlines at the end of the text.
+func trailingNewlines(text string) int {
+	return len(text) - len(strings.TrimRight(text, "\n"))
+}
+
+// Logs the error and exits with exitDriftError.
+func driftFatal(err error) {
+	log.Printf("drift: %v", err)
+	os.Exit(exitDriftError)
+}
+
+// Returns the hunks of a unified diff without context turning the old lines into the new ones.
+func diffLines(old, new []string) string {
+	// Length of the longest common subsequence of the lines following each pair of lines.
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		if i < len(old) && j < len(new) && old[i] == new[j] {
+			i++
+			j++
+			continue
+		}
+
+		// Lines are removed and added until the next common line.
+		oldStart, newStart := i, j
+		for i < len(old) && (j == len(new) || (old[i] != new[j] && lcs[i+1][j] >= lcs[i][j+1])) {
+			i++
+		}
+		for j < len(new) && (i == len(old) || old[i] != new[j]) {
+			j++
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, i-oldStart), hunkRange(newStart, j-newStart))
+		for _, line := range old[oldStart:i] {
+			b.WriteString("-" + strings.TrimSuffix(line, "\n") + "\n")
+		}
+		for _, line := range new[newStart:j] {
+			b.WriteString("+" + strings.TrimSuffix(line, "\n") + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// Returns the range of lines of a hunk as written in unified diffs.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
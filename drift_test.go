@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name, old, new, want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"changed", "a\nb\nc\n", "a\nx\nc\n", "@@ -2 +2 @@\n-b\n+x\n"},
+		{"added", "a\nc\n", "a\nb\nc\n", "@@ -1,0 +2 @@\n+b\n"},
+		{"removed", "a\nb\nc\n", "a\nc\n", "@@ -2 +1,0 @@\n-b\n"},
+		{"several hunks", "a\nb\nc\nd\n", "x\nb\nc\ny\nz\n", "@@ -1 +1 @@\n-a\n+x\n@@ -4 +4,2 @@\n-d\n+y\n+z\n"},
+		{"from empty", "", "a\nb\n", "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffLines(strings.SplitAfter(tt.old, "\n"), strings.SplitAfter(tt.new, "\n"))
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriftOutputs(t *testing.T) {
+	setValues(t, map[string]string{"Host": "db.example.com"})
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "app.conf.tmpl"), filepath.Join(dir, "app.conf")
+	if err := ioutil.WriteFile(src, []byte("host={{Host}}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, stamp, deployed string
+		want                  bool
+	}{
+		{"written to file", "", "host=db.example.com", true},
+		{"written to standard output", "", "host=db.example.com\n", true},
+		{"edited", "", "host=localhost\n", false},
+		{"extra newlines", "", "host=db.example.com\n\n", false},
+		{"stamped", "#", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, "stamp", tt.stamp)
+			deployed := tt.deployed
+			if tt.stamp != "" {
+				// The file is deployed with the stamp of the same template and values, through standard output.
+				r, err := renderSource(src, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				output, _ := stampOutput("", r.text, r.output)
+				deployed = output + "\n"
+			}
+			if err := ioutil.WriteFile(dst, []byte(deployed), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			expected, actual, err := driftOutputs(src, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expected == actual; got != tt.want {
+				t.Errorf("got match %v, want %v: expected %q, deployed %q", got, tt.want, expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+//go:build unix
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// Environment variable containing the table used by the entrypoint command.
+	envTable = "DYNSUBST_TABLE"
+	// Prefix of the environment variables declaring templates for the entrypoint command.
+	// Values have the form "source:destination" and are rendered in lexical order of their names.
+	envTemplatePrefix = "DYNSUBST_TEMPLATE_"
+)
+
+// Renders the templates declared in the environment and runs the supplied command.
+// As it is meant to be PID 1 in a container, signals are forwarded to the command
+// and orphaned processes are reaped until the command exits.
+func runEntrypoint(args []string) {
+	if len(args) < 1 {
+		log.Fatal("entrypoint: missing command")
+	}
+
+	table = os.Getenv(envTable)
+	if table == "" {
+		log.Fatalf("entrypoint: %s is not set", envTable)
+	}
+
+	var names []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, envTemplatePrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		paths := strings.SplitN(os.Getenv(name), ":", 2)
+		if len(paths) != 2 {
+			log.Fatalf("entrypoint: invalid value for %s: expected \"source:destination\"", name)
+		}
+		if err := renderFile(paths[0], paths[1]); err != nil {
+			log.Fatalf("entrypoint: %v", withSuggestions(err))
+		}
+	}
+
+	if err := saveCache(); err != nil {
+		log.Printf("entrypoint: warning: error saving cache: %v", err)
+	}
+	stopTracing()
+	stopInterrupts()
+	os.Exit(runChild(args))
+}
+
+// Renders the source template into the destination file, preserving the permissions of the source.
+func renderFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	input, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	defer saveDirectives()()
+	text, err := applyDirectives(src, string(input))
+	if err != nil {
+		return err
+	}
+	if err := validateTemplate(text); err != nil {
+		return err
+	}
+
+	templateDir = filepath.Dir(src)
+	end := startSpan("render", attribute.String("file", src))
+	startProgress(text)
+	output, err := renderTemplate(text)
+	endProgress()
+	end(err)
+	if err != nil {
+		return err
+	}
+	output, upToDate := stampOutput(dst, text, output)
+	if upToDate {
+		return nil
+	}
+
+	return writeFile(dst, []byte(output), info.Mode().Perm())
+}
+
+// Runs the command until it exits and returns its exit code.
+func runChild(args []string) int {
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Fatalf("entrypoint: %v", err)
+	}
+	pid := cmd.Process.Pid
+
+	for sig := range signals {
+		if sig == syscall.SIGURG {
+			// Used internally by the Go runtime for preemption.
+			continue
+		}
+		if sig != syscall.SIGCHLD {
+			// Errors are ignored as the command may have already exited.
+			_ = cmd.Process.Signal(sig)
+			continue
+		}
+
+		// Reap every child that has exited, including orphaned processes inherited as PID 1.
+		for {
+			var status syscall.WaitStatus
+			wpid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+			if err != nil || wpid <= 0 {
+				break
+			}
+			if wpid != pid {
+				continue
+			}
+			if status.Signaled() {
+				return 128 + int(status.Signal())
+			}
+			return status.ExitStatus()
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "log"
+
+// The entrypoint command relies on process reaping, which is only available on Unix systems.
+func runEntrypoint(args []string) {
+	log.Fatal("entrypoint: not supported on this platform")
+}
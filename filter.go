@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	// Filter supplied with -filter, narrowing down the items of every key.
+	filter string
+	// Filter specified by the placeholder being resolved, if any, which takes precedence over -filter.
+	currentFilter string
+)
+
+// Returns the filter applying to the placeholder being resolved.
+func activeFilter() string {
+	if currentFilter != "" {
+		return currentFilter
+	}
+	return filter
+}
+
+// Parses a filter made of comma-separated conditions which must all be met,
+// of the form "Attribute=value" or "Attribute!=value": "Active=true,Stage!=draft".
+// Values are booleans, numbers or strings, which can be quoted to prevent them from being taken as either.
+func parseFilter(expr string) (map[string]*dynamodb.Condition, error) {
+	conditions := make(map[string]*dynamodb.Condition)
+	for _, part := range strings.Split(expr, ",") {
+		op := "EQ"
+		i := strings.Index(part, "=")
+		if i > 0 && part[i-1] == '!' {
+			op = "NE"
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("invalid filter \"%s\": expected \"Attribute=value\" or \"Attribute!=value\"", expr)
+		}
+		attr := strings.TrimSpace(strings.TrimSuffix(part[:i], "!"))
+		if attr == "" {
+			return nil, fmt.Errorf("invalid filter \"%s\": missing attribute", expr)
+		}
+
+		conditions[attr] = &dynamodb.Condition{
+			ComparisonOperator: aws.String(op),
+			AttributeValueList: []*dynamodb.AttributeValue{filterValue(strings.TrimSpace(part[i+1:]))},
+		}
+	}
+
+	return conditions, nil
+}
+
+// Returns the attribute value of a filter value.
+func filterValue(value string) *dynamodb.AttributeValue {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return &dynamodb.AttributeValue{S: aws.String(unquoted)}
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(b)}
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return &dynamodb.AttributeValue{N: aws.String(value)}
+	}
+	return &dynamodb.AttributeValue{S: aws.String(value)}
+}
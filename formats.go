@@ -0,0 +1,21 @@
+package main
+
+// Formats of the input, selected with -format.
+const (
+	// Replace placeholders anywhere in the input.
+	formatText = "text"
+	// Replace placeholders inside JSON string values only, escaping values as needed.
+	formatJSON = "json"
+	// Replace placeholders inside YAML scalar values only, quoting values as needed.
+	formatYAML = "yaml"
+	// Replace placeholders according to TOML syntax, escaping values inside strings and quoting them outside.
+	formatTOML = "toml"
+	// Replace placeholders inside INI values only, quoting values as needed.
+	formatINI = "ini"
+	// Replace placeholders inside values of systemd environment files or Environment= assignments, quoting them as needed.
+	formatSystemdEnv = "systemd-env"
+	// Replace placeholders inside Java properties values only, escaping values as needed.
+	formatProperties = "properties"
+	// Replace placeholders in XML documents, escaping values as entities.
+	formatXML = "xml"
+)
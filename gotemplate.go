@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	// Engine replacing placeholders as described in the extended help.
+	engineDynsubst = "dynsubst"
+	// Engine parsing templates with text/template, which provides conditionals, loops and pipelines.
+	engineGoTemplate = "gotemplate"
+)
+
+// Returns the template rendered with text/template.
+// Values are retrieved with the following functions:
+//
+//	{{ get "Username" }}               Value of a key, accepting modifiers as placeholders do.
+//	{{ get "Password" | decrypt }}     Value decrypted with AWS KMS.
+//	{{ secret "Password" }}            Shorthand for the above.
+func renderGoTemplate(text string) (string, error) {
+	t, err := template.New("dynsubst").Funcs(templateFuncs()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, nil); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// Returns the functions available to templates rendered with text/template.
+func templateFuncs() template.FuncMap {
+	get := func(key string) (string, error) {
+		return resolve(fmt.Sprintf("{{%s}}", key))
+	}
+
+	funcs := template.FuncMap{
+		"get":     get,
+		"decrypt": decrypt,
+		"secret": func(key string) (string, error) {
+			value, err := get(key)
+			if err != nil {
+				return "", err
+			}
+			return decrypt(value)
+		},
+	}
+	for name, f := range utilityFuncs {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
+// Utility functions available to templates rendered with text/template,
+// following the names and argument order of the Sprig library so that they can be used in pipelines:
+//
+//	{{ get "Timeout" | default "30" }}
+//	{{ ternary "on" "off" (eq (get "Debug") "true") }}
+//	{{ get "Certificate" | indent 4 }}
+var utilityFuncs = template.FuncMap{
+	// Returns the value, or the default one when it is empty.
+	"default": func(d, value string) string {
+		if value == "" {
+			return d
+		}
+		return value
+	},
+	// Returns the first value when the condition is true and the second one otherwise.
+	"ternary": func(vt, vf interface{}, condition bool) interface{} {
+		if condition {
+			return vt
+		}
+		return vf
+	},
+	// Returns the value encoded in JSON.
+	"toJson": func(v interface{}) (string, error) {
+		output, err := json.Marshal(v)
+		return string(output), err
+	},
+	// Returns the value encoded in base64.
+	"b64enc": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+	// Returns the value with every line indented by the amount of spaces specified.
+	"indent": func(spaces int, value string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.Replace(value, "\n", "\n"+pad, -1)
+	},
+	// Returns the value without leading and trailing whitespace.
+	"trim": strings.TrimSpace,
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Dependency graph of templates and keys, written in the DOT language.
+type dependencyGraph struct {
+	out strings.Builder
+	// Nodes added so far, to visit each template and key only once.
+	nodes map[string]bool
+}
+
+// Prints a Graphviz DOT graph of the templates in the files, the templates they include and the keys they reference.
+// With -recursive, the values of the keys are retrieved to also add the keys they reference.
+// Keys which do not exist are drawn with dashed lines.
+// Ex.: dynsubst -recursive graph app-settings config.yaml | dot -Tsvg > config.svg
+func runGraph(args []string) {
+	if len(args) < 2 {
+		log.Fatal("graph: usage: graph table file...")
+	}
+	table = args[0]
+
+	g := &dependencyGraph{nodes: map[string]bool{}}
+	g.out.WriteString("digraph dynsubst {\n")
+	g.out.WriteString("  rankdir=LR;\n")
+	for _, file := range args[1:] {
+		if err := g.addFile(file); err != nil {
+			log.Fatalf("graph: %v", err)
+		}
+	}
+	g.out.WriteString("}\n")
+
+	fmt.Print(g.out.String())
+}
+
+// Adds the template in the file, the templates it includes and the keys it references.
+func (g *dependencyGraph) addFile(file string) error {
+	if g.nodes[file] {
+		return nil
+	}
+	g.nodes[file] = true
+	fmt.Fprintf(&g.out, "  %q [shape=note];\n", file)
+
+	input, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	text := string(input)
+
+	for _, input := range placeholderRe.FindAllString(text, -1) {
+		p, err := parsePlaceholder(input)
+		if err != nil || p.skipped != "" || p.source != modInclude {
+			continue
+		}
+		path := p.key
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		fmt.Fprintf(&g.out, "  %q -> %q [style=dotted];\n", file, path)
+		if err := g.addFile(path); err != nil {
+			return err
+		}
+	}
+
+	return g.addReferences(file, text)
+}
+
+// Adds the keys referenced in the text as dependencies of a node.
+func (g *dependencyGraph) addReferences(from, text string) error {
+	edges := map[string]bool{}
+	for _, ref := range references(from, text) {
+		to := ref.Table + ":" + ref.Key
+		if !edges[to] {
+			edges[to] = true
+			fmt.Fprintf(&g.out, "  %q -> %q;\n", from, to)
+		}
+		if err := g.addKey(ref.Table, ref.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Adds a key and, with -recursive, the keys referenced in its value.
+func (g *dependencyGraph) addKey(table, key string) error {
+	node := table + ":" + key
+	if g.nodes[node] {
+		return nil
+	}
+	g.nodes[node] = true
+	if !recursive || table == "" {
+		fmt.Fprintf(&g.out, "  %q [label=%q];\n", node, key)
+		return nil
+	}
+
+	value, err := fetch(table, key)
+	if errors.Is(err, errNotFound) {
+		fmt.Fprintf(&g.out, "  %q [label=%q, style=dashed];\n", node, key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&g.out, "  %q [label=%q];\n", node, key)
+
+	return g.addReferences(node, value)
+}
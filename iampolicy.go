@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Access required to render templates, as found by analyzing them.
+type requiredAccess struct {
+	// Actions required on each table.
+	tables map[string]map[string]bool
+	// Actions required on AWS KMS keys.
+	keys map[string]bool
+	// Files analyzed so far, to analyze included templates only once.
+	files map[string]bool
+	// First placeholder whose value is decrypted, if any.
+	decrypted *placeholder
+}
+
+// Returns the access required by the flags supplied, before analyzing any template.
+func newRequiredAccess() *requiredAccess {
+	a := &requiredAccess{tables: map[string]map[string]bool{}, keys: map[string]bool{}, files: map[string]bool{}}
+	if verifyKey != "" {
+		a.keys["kms:Verify"] = true
+	}
+	if manifestKey != "" {
+		a.keys["kms:Sign"] = true
+	}
+
+	return a
+}
+
+// A statement of an AWS IAM policy.
+type policyStatement struct {
+	Effect   string
+	Action   []string
+	Resource []string
+}
+
+// Prints the minimal AWS IAM policy required to render the templates with the flags supplied.
+// Tables are matched in any region and account, and AWS KMS actions are allowed with any key,
+// as the keys used to encrypt values are only known once they are retrieved.
+// Ex.: dynsubst iam-policy app-settings config.yaml nginx.conf
+func runIAMPolicy(args []string) {
+	if len(args) < 2 {
+		log.Fatal("iam-policy: usage: iam-policy table file...")
+	}
+	table = args[0]
+
+	access := newRequiredAccess()
+	for _, file := range args[1:] {
+		if err := access.analyzeFile(file); err != nil {
+			log.Fatalf("iam-policy: %v", err)
+		}
+	}
+
+	var names []string
+	for name := range access.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var statements []policyStatement
+	for _, name := range names {
+		var actions []string
+		for action := range access.tables[name] {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		statements = append(statements, policyStatement{
+			Effect:   "Allow",
+			Action:   actions,
+			Resource: []string{fmt.Sprintf("arn:aws:dynamodb:*:*:table/%s", name)},
+		})
+	}
+	if len(access.keys) > 0 {
+		var actions []string
+		for action := range access.keys {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		statements = append(statements, policyStatement{
+			Effect:   "Allow",
+			Action:   actions,
+			Resource: []string{"*"},
+		})
+	}
+
+	output, err := json.MarshalIndent(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	}, "", "  ")
+	if err != nil {
+		log.Fatalf("iam-policy: %v", err)
+	}
+	fmt.Println(string(output))
+}
+
+// Analyzes the template in the file and the templates it includes.
+func (a *requiredAccess) analyzeFile(file string) error {
+	if a.files[file] {
+		return nil
+	}
+	a.files[file] = true
+
+	input, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	return a.analyzeText(file, string(input))
+}
+
+// Analyzes the template read from the file, which is empty for standard input.
+func (a *requiredAccess) analyzeText(file, text string) error {
+	for _, m := range blockRe.FindAllStringSubmatch(text, -1) {
+		switch m[1] {
+		case blockIf, blockIfEq:
+			fields := strings.Fields(m[2])
+			if len(fields) == 0 {
+				return fmt.Errorf("invalid block %s: expected a key", m[0])
+			}
+			if err := a.analyzePlaceholder(file, "{{"+fields[0]+"}}"); err != nil {
+				return err
+			}
+		case blockEach:
+			a.require(table, "dynamodb:Scan")
+		}
+	}
+	for _, input := range placeholderRe.FindAllString(text, -1) {
+		if strings.HasPrefix(input, "{{#") || strings.HasPrefix(input, "{{/") {
+			continue
+		}
+		if err := a.analyzePlaceholder(file, input); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Analyzes a placeholder found in the template in the file.
+func (a *requiredAccess) analyzePlaceholder(file, input string) error {
+	p, err := parsePlaceholder(input)
+	if err != nil {
+		return err
+	}
+	if p.skipped != "" {
+		return nil
+	}
+	for _, m := range p.modifiers {
+		if m.name == modDecrypt {
+			a.keys["kms:Decrypt"] = true
+			if a.decrypted == nil && p.source == "" {
+				a.decrypted = p
+			}
+		}
+	}
+
+	switch p.source {
+	case modInclude:
+		path := p.key
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		return a.analyzeFile(path)
+	case modJoin:
+		a.require(p.table, "dynamodb:Scan")
+		return nil
+	case modPlugin:
+		return nil
+	}
+	if strings.HasPrefix(p.key, "@") || strings.HasPrefix(p.key, ".") {
+		return nil
+	}
+
+	a.require(p.table, "dynamodb:Query")
+	if _, version := splitVersion(p.key); version != "" {
+		a.require(p.table, "dynamodb:DescribeTable")
+	}
+	if ignoreCase {
+		a.require(p.table, "dynamodb:Scan")
+	}
+	if prefetchMode || scanThreshold > 0 {
+		a.require(p.table, "dynamodb:DescribeTable")
+		a.require(p.table, "dynamodb:GetItem")
+		a.require(p.table, "dynamodb:Scan")
+	}
+	if ttlAttr == "" {
+		a.require(p.table, "dynamodb:DescribeTimeToLive")
+	}
+
+	return nil
+}
+
+// Records that the action is required on the table, if any.
+// Keys not found in the first table supplied with -tables are looked up in the others,
+// which require the same actions.
+func (a *requiredAccess) require(table, action string) {
+	if table == "" {
+		return
+	}
+	required := []string{table}
+	if len(fallbackTables) > 0 && table == fallbackTables[0] {
+		required = fallbackTables
+	}
+	for _, t := range required {
+		if a.tables[t] == nil {
+			a.tables[t] = map[string]bool{}
+		}
+		a.tables[t][action] = true
+	}
+}
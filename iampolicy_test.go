@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeText(t *testing.T) {
+	setValues(t, nil)
+	setFlag(t, "ttl-attr", "TTL")
+
+	a := newRequiredAccess()
+	text := "{{Host}} {{DECRYPT:TABLE=credentials:Password}} {{#IF Debug}}on{{/IF}} {{#EACH app/*}}{{.Value}}{{/EACH}}"
+	if err := a.analyzeText("", text); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]bool{
+		"test-settings": {"dynamodb:Query": true, "dynamodb:Scan": true},
+		"credentials":   {"dynamodb:Query": true},
+	}
+	if !reflect.DeepEqual(a.tables, want) {
+		t.Errorf("got tables %v, want %v", a.tables, want)
+	}
+	if !a.keys["kms:Decrypt"] {
+		t.Errorf("got keys %v, want kms:Decrypt", a.keys)
+	}
+}
+
+func TestAnalyzeTextInvalidBlock(t *testing.T) {
+	setValues(t, nil)
+
+	for _, text := range []string{"{{#IF   }}", "x {{#IFEQ \t}}"} {
+		if err := newRequiredAccess().analyzeText("", text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
+
+func TestAnalyzeTextFallbackTables(t *testing.T) {
+	setValues(t, nil)
+	setFlag(t, "ttl-attr", "TTL")
+	saved := fallbackTables
+	t.Cleanup(func() { fallbackTables = saved })
+	fallbackTables = []string{table, "shared-settings", "default-settings"}
+
+	a := newRequiredAccess()
+	if err := a.analyzeText("", "{{Host}} {{TABLE=credentials:Password}}"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]bool{
+		"test-settings":    {"dynamodb:Query": true},
+		"shared-settings":  {"dynamodb:Query": true},
+		"default-settings": {"dynamodb:Query": true},
+		"credentials":      {"dynamodb:Query": true},
+	}
+	if !reflect.DeepEqual(a.tables, want) {
+		t.Errorf("got tables %v, want %v", a.tables, want)
+	}
+}
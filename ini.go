@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns the INI document after replacing placeholders in values, quoting them when needed.
+// Placeholders in comments, section headers and keys are left as is.
+func renderINI(text string) (string, error) {
+	lines := strings.Split(text, "\n")
+	for n, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 || !strings.Contains(line[i+1:], "{{") {
+			continue
+		}
+
+		value, err := iniValue(line[i+1:])
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", n+1, err)
+		}
+		lines[n] = line[:i+1] + value
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Returns the raw value of an INI entry, including surrounding whitespace and trailing comments,
+// after replacing its placeholders.
+func iniValue(raw string) (string, error) {
+	raw, comment := splitINIComment(raw)
+	value := strings.TrimSpace(raw)
+	leading := raw[:strings.Index(raw, value)]
+	trailing := raw[len(leading)+len(value):]
+
+	quoted := len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"'
+	if quoted {
+		value = value[1 : len(value)-1]
+	}
+	output, err := render(value)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(output, "\r\n") {
+		return "", fmt.Errorf("values cannot span multiple lines")
+	}
+
+	// Values with characters which would be interpreted otherwise are quoted.
+	if quoted || output == "" || output != strings.TrimSpace(output) || strings.ContainsAny(output, `;#"\`) {
+		output = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(output) + `"`
+	}
+
+	return leading + output + trailing + comment, nil
+}
+
+// Returns the raw value of an INI entry and its trailing comment, if any.
+// Comments start with ";" or "#" preceded by whitespace, outside of quotes.
+func splitINIComment(raw string) (string, string) {
+	var quoted bool
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case quoted && raw[i] == '\\':
+			i++
+		case raw[i] == '"':
+			quoted = !quoted
+		case !quoted && (raw[i] == ';' || raw[i] == '#') && i > 0 && (raw[i-1] == ' ' || raw[i-1] == '\t'):
+			return raw[:i], raw[i:]
+		}
+	}
+
+	return raw, ""
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestRenderINI(t *testing.T) {
+	setValues(t, map[string]string{
+		"Host":     "db.example.com",
+		"Password": `p;a"ss`,
+		"Padded":   " x ",
+	})
+
+	testRender(t, renderINI, []renderTest{
+		{"plain", "host = {{Host}}", "host = db.example.com"},
+		{"colon", "host: {{Host}}", "host: db.example.com"},
+		{"quoted when needed", "password = {{Password}}", `password = "p;a\"ss"`},
+		{"surrounding whitespace", "padded={{Padded}}", `padded=" x "`},
+		{"already quoted", `host = "{{Host}}"`, `host = "db.example.com"`},
+		{"inline comment", "host = {{Host}} ; primary", "host = db.example.com ; primary"},
+		{"inline hash comment", "host = {{Host}}  # primary", "host = db.example.com  # primary"},
+		{"comment after quotes", `host = "{{Host}} ;" ; primary`, `host = "db.example.com ;" ; primary`},
+		{"comment lines", "; {{Host}}\n# {{Host}}", "; {{Host}}\n# {{Host}}"},
+		{"sections", "[{{Host}}]\nhost = {{Host}}", "[{{Host}}]\nhost = db.example.com"},
+	})
+}
+
+func TestRenderINIMultiLine(t *testing.T) {
+	setValues(t, map[string]string{"Cert": "line1\nline2"})
+
+	if _, err := renderINI("cert = {{Cert}}"); err == nil {
+		t.Error("got no error for a multi-line value")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Exit code when interrupted by SIGINT or SIGTERM, following the convention of shells for SIGINT.
+const exitInterrupted = 130
+
+var (
+	// Held while writing files, so that interrupts wait for them to be complete.
+	writing sync.Mutex
+	// Interrupts received while rendering.
+	interrupts chan os.Signal
+)
+
+// Exits with exitInterrupted on SIGINT or SIGTERM, without issuing further AWS requests.
+// Files are never left partially written, as they are written with writeFile.
+func handleInterrupts() {
+	interrupts = make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-interrupts
+		if !ok {
+			return
+		}
+		writing.Lock()
+		endProgress()
+		log.Printf("interrupted by %v", sig)
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// Stops handling interrupts, as when they must be forwarded to a command instead.
+func stopInterrupts() {
+	signal.Stop(interrupts)
+	close(interrupts)
+}
+
+// Writes the data to the file through a temporary file in the same directory, renamed over it once complete,
+// so that the file is either left as it was or completely written.
+// The permissions of an existing file are preserved, and symbolic links are followed.
+// Files already containing the data are left untouched, preserving their modification time.
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(name); err == nil {
+		name = target
+	}
+	if unchanged(name, data) {
+		return nil
+	}
+	if info, err := os.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	writing.Lock()
+	defer writing.Unlock()
+
+	temp, err := writeTemp(name, data, perm, -1, -1)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(temp, name); err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	return nil
+}
+
+// Reports whether the file exists and already contains the data.
+func unchanged(name string, data []byte) bool {
+	current, err := ioutil.ReadFile(name)
+	return err == nil && bytes.Equal(current, data)
+}
+
+// Writes the data to a new temporary file in the same directory as the file, so that it can be renamed over it,
+// and returns its name. The owner and group are left unchanged when -1.
+// The caller must hold the writing lock until the temporary file is renamed or removed.
+func writeTemp(name string, data []byte, perm os.FileMode, uid, gid int) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil && (uid != -1 || gid != -1) {
+		err = f.Chown(uid, gid)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Renders a JSON document while copying it as is, so that formatting and key order are preserved.
+type jsonRenderer struct {
+	input string
+	pos   int
+	out   strings.Builder
+	// Steps from the root of the document to the value being rendered.
+	steps []pathStep
+}
+
+// Returns the JSON document after replacing placeholders inside its string values.
+func renderJSON(text string) (string, error) {
+	r := &jsonRenderer{input: text}
+	r.space()
+	if err := r.value(); err != nil {
+		return "", err
+	}
+	r.space()
+	if r.pos != len(r.input) {
+		return "", r.errorf("unexpected data after JSON value")
+	}
+
+	output := r.out.String()
+	if !json.Valid([]byte(output)) {
+		return "", fmt.Errorf("rendered JSON is not valid")
+	}
+
+	return output, nil
+}
+
+func (r *jsonRenderer) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", r.pos, fmt.Sprintf(format, a...))
+}
+
+// Returns the next character of the input, or zero at the end of it.
+func (r *jsonRenderer) peek() byte {
+	if r.pos >= len(r.input) {
+		return 0
+	}
+	return r.input[r.pos]
+}
+
+// Copies whitespace.
+func (r *jsonRenderer) space() {
+	for r.pos < len(r.input) && strings.IndexByte(" \t\r\n", r.input[r.pos]) >= 0 {
+		r.out.WriteByte(r.input[r.pos])
+		r.pos++
+	}
+}
+
+// Copies the expected character.
+func (r *jsonRenderer) expect(c byte) error {
+	if r.peek() != c {
+		return r.errorf("expected '%c'", c)
+	}
+	r.out.WriteByte(c)
+	r.pos++
+	return nil
+}
+
+func (r *jsonRenderer) value() error {
+	switch r.peek() {
+	case '{':
+		return r.object()
+	case '[':
+		return r.array()
+	case '"':
+		return r.stringValue()
+	}
+
+	// Numbers, booleans and null are copied as is.
+	start := r.pos
+	for r.pos < len(r.input) && strings.IndexByte(",]} \t\r\n", r.input[r.pos]) < 0 {
+		r.pos++
+	}
+	if r.pos == start {
+		return r.errorf("expected value")
+	}
+	r.out.WriteString(r.input[start:r.pos])
+	return nil
+}
+
+func (r *jsonRenderer) object() error {
+	r.expect('{')
+	r.space()
+	if r.peek() == '}' {
+		return r.expect('}')
+	}
+	for {
+		raw, key, err := r.literal()
+		if err != nil {
+			return err
+		}
+		// Keys are copied as is.
+		r.out.WriteString(raw)
+		r.space()
+		if err := r.expect(':'); err != nil {
+			return err
+		}
+		r.space()
+		r.steps = append(r.steps, pathStep{key: key, index: -1})
+		err = r.value()
+		r.steps = r.steps[:len(r.steps)-1]
+		if err != nil {
+			return err
+		}
+		r.space()
+		if r.peek() != ',' {
+			return r.expect('}')
+		}
+		r.expect(',')
+		r.space()
+	}
+}
+
+func (r *jsonRenderer) array() error {
+	r.expect('[')
+	r.space()
+	if r.peek() == ']' {
+		return r.expect(']')
+	}
+	for index := 0; ; index++ {
+		r.steps = append(r.steps, pathStep{index: index})
+		err := r.value()
+		r.steps = r.steps[:len(r.steps)-1]
+		if err != nil {
+			return err
+		}
+		r.space()
+		if r.peek() != ',' {
+			return r.expect(']')
+		}
+		r.expect(',')
+		r.space()
+	}
+}
+
+// Consumes a string literal and returns it both raw and decoded.
+func (r *jsonRenderer) literal() (string, string, error) {
+	if r.peek() != '"' {
+		return "", "", r.errorf("expected string")
+	}
+	start := r.pos
+	for r.pos++; r.pos < len(r.input) && r.input[r.pos] != '"'; r.pos++ {
+		if r.input[r.pos] == '\\' {
+			r.pos++
+		}
+	}
+	if r.pos >= len(r.input) {
+		return "", "", r.errorf("unterminated string")
+	}
+	r.pos++
+
+	raw := r.input[start:r.pos]
+	var s string
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return "", "", r.errorf("%v", err)
+	}
+
+	return raw, s, nil
+}
+
+// Copies a string value after replacing its placeholders.
+func (r *jsonRenderer) stringValue() error {
+	raw, s, err := r.literal()
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(s, "{{") || !targeted(r.steps) {
+		r.out.WriteString(raw)
+		return nil
+	}
+
+	if native && placeholderRe.FindString(s) == s {
+		value, err := resolve(s)
+		if err != nil {
+			return err
+		}
+		if json.Valid([]byte(value)) {
+			r.out.WriteString(value)
+			return nil
+		}
+		return r.writeString(value)
+	}
+
+	output, err := render(s)
+	if err != nil {
+		return err
+	}
+	return r.writeString(output)
+}
+
+// Writes the string as a JSON string literal.
+func (r *jsonRenderer) writeString(s string) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	r.out.WriteString(strings.TrimSuffix(b.String(), "\n"))
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestRenderJSON(t *testing.T) {
+	setValues(t, map[string]string{
+		"Host":     "db.example.com",
+		"Password": "p\"a\\ss\n",
+		"Port":     "5432",
+	})
+
+	testRender(t, renderJSON, []renderTest{
+		{"string value", `{"host": "{{Host}}"}`, `{"host": "db.example.com"}`},
+		{"escaped value", `{"password": "{{Password}}"}`, `{"password": "p\"a\\ss\n"}`},
+		{"embedded placeholder", `{"url": "postgres://{{Host}}:{{Port}}"}`, `{"url": "postgres://db.example.com:5432"}`},
+		{"keys left as is", `{"{{Host}}": 1}`, `{"{{Host}}": 1}`},
+		{"formatting preserved", "{\n  \"b\": [\"{{Port}}\", true],\n  \"a\": null\n}", "{\n  \"b\": [\"5432\", true],\n  \"a\": null\n}"},
+	})
+}
+
+func TestRenderJSONNative(t *testing.T) {
+	setValues(t, map[string]string{"Port": "5432", "Host": "db.example.com"})
+	setFlag(t, "native", "true")
+
+	testRender(t, renderJSON, []renderTest{
+		{"valid JSON", `{"port": "{{Port}}"}`, `{"port": 5432}`},
+		{"string", `{"host": "{{Host}}"}`, `{"host": "db.example.com"}`},
+		{"embedded placeholder", `{"port": "{{Port}}0"}`, `{"port": "54320"}`},
+	})
+}
+
+func TestRenderJSONInvalid(t *testing.T) {
+	setValues(t, nil)
+
+	for _, input := range []string{`{"host": }`, `{"host": "x"`, `{} {}`} {
+		if _, err := renderJSON(input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
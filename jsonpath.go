@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A step from a node of a JSON or YAML document to one of its children:
+// either the key of an object or the index of an array, in which case the key is empty.
+type pathStep struct {
+	key   string
+	index int
+}
+
+// A segment of a path supplied with -path.
+type pathSegment struct {
+	key   string
+	index int
+	// Whether the segment refers to an index rather than a key.
+	isIndex bool
+	// Whether the segment matches any key (".*") or any index ("[*]").
+	any bool
+}
+
+// Reports whether the segment matches the step.
+func (s pathSegment) matches(step pathStep) bool {
+	if s.isIndex != (step.index >= 0) {
+		return false
+	}
+	if s.any {
+		return true
+	}
+	if s.isIndex {
+		return s.index == step.index
+	}
+	return s.key == step.key
+}
+
+// Paths supplied with -path, restricting substitution in JSON and YAML documents to the values at them.
+// Paths are written as in jq: ".spec.containers[*].env[*].value".
+type pathFlag [][]pathSegment
+
+func (p *pathFlag) String() string {
+	return fmt.Sprintf("%d paths", len(*p))
+}
+
+func (p *pathFlag) Set(expr string) error {
+	var segments []pathSegment
+	rest := expr
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			key := rest[1:end]
+			if key == "" {
+				return fmt.Errorf("invalid path \"%s\": empty key", expr)
+			}
+			segments = append(segments, pathSegment{key: key, index: -1, any: key == "*"})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return fmt.Errorf("invalid path \"%s\": unterminated index", expr)
+			}
+			segment := pathSegment{isIndex: true, any: rest[1:end] == "*"}
+			if !segment.any {
+				index, err := strconv.Atoi(rest[1:end])
+				if err != nil || index < 0 {
+					return fmt.Errorf("invalid path \"%s\": invalid index \"%s\"", expr, rest[1:end])
+				}
+				segment.index = index
+			}
+			segments = append(segments, segment)
+			rest = rest[end+1:]
+		default:
+			return fmt.Errorf("invalid path \"%s\": expected '.' or '['", expr)
+		}
+	}
+	*p = append(*p, segments)
+
+	return nil
+}
+
+// Reports whether the value reached by the steps from the root of the document should be rendered.
+// Every value is rendered when no paths are supplied.
+func targeted(steps []pathStep) bool {
+	if len(paths) == 0 {
+		return true
+	}
+
+	for _, segments := range paths {
+		if len(segments) != len(steps) {
+			continue
+		}
+		matched := true
+		for i, segment := range segments {
+			if !segment.matches(steps[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
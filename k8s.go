@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directory where Kubernetes mounts the credentials of the service account of pods.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Values of a flag that can be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Renders templates into a Kubernetes Secret, which is created or updated through the Kubernetes API
+// without writing them to disk. Templates supplied with -from are stored under their file name
+// (or the key specified as "key=file"), as with "kubectl create secret generic --from-file",
+// while those supplied with -from-env-file are stored one key per line, as with "--from-env-file".
+// The API is reached with the credentials of the service account when running in a pod,
+// or through the URL supplied with -server otherwise, such as that of "kubectl proxy".
+// Ex.: dynsubst -table app-settings k8s-secret -name app-secrets -namespace prod -from-env-file template.env
+func runK8sSecret(args []string) {
+	fs := flag.NewFlagSet("k8s-secret", flag.ExitOnError)
+	name := fs.String("name", "", "specify name of the secret")
+	namespace := fs.String("namespace", "", "specify namespace of the secret (default: that of the pod, or \"default\")")
+	server := fs.String("server", "", "specify URL of the Kubernetes API (default: the one of the cluster of the pod)")
+	var files, envFiles listFlag
+	fs.Var(&files, "from", "store the rendered template as a key, as \"[key=]file\" (can be repeated)")
+	fs.Var(&envFiles, "from-env-file", "store each \"KEY=value\" line of the rendered template as a key (can be repeated)")
+	fs.Parse(args)
+	if *name == "" || len(files)+len(envFiles) == 0 {
+		log.Fatal("k8s-secret: usage: k8s-secret -name name [-namespace namespace] -from [key=]file... -from-env-file file...")
+	}
+	if *namespace == "" {
+		*namespace = "default"
+		if ns, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+			*namespace = strings.TrimSpace(string(ns))
+		}
+	}
+	table = tables[""]
+
+	data := map[string][]byte{}
+	for _, file := range files {
+		key := filepath.Base(file)
+		if i := strings.Index(file, "="); i >= 0 {
+			key, file = file[:i], file[i+1:]
+		}
+		r, err := renderSource(file, true)
+		if err != nil {
+			log.Fatalf("k8s-secret: %s: %v", file, err)
+		}
+		data[key] = []byte(r.output)
+	}
+	for _, file := range envFiles {
+		r, err := renderSource(file, true)
+		if err != nil {
+			log.Fatalf("k8s-secret: %s: %v", file, err)
+		}
+		scanner := bufio.NewScanner(strings.NewReader(r.output))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				log.Fatalf("k8s-secret: %s: invalid line: expected \"KEY=value\"", file)
+			}
+			data[parts[0]] = []byte(parts[1])
+		}
+	}
+	if err := saveCache(); err != nil {
+		log.Printf("k8s-secret: warning: error saving cache: %v", err)
+	}
+
+	if err := applySecret(*server, *namespace, *name, data); err != nil {
+		log.Fatalf("k8s-secret: %v", err)
+	}
+}
+
+// Creates or updates the secret with server-side apply, so that keys managed by others are preserved.
+func applySecret(server, namespace, name string, data map[string][]byte) error {
+	client, err := httpClient()
+	if err != nil {
+		return err
+	}
+	var token []byte
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return fmt.Errorf("not running in a Kubernetes pod, use -server")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+
+		token, err = ioutil.ReadFile(filepath.Join(serviceAccountDir, "token"))
+		if err != nil {
+			return err
+		}
+		if caBundle == "" {
+			pem, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+			if err != nil {
+				return err
+			}
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(pem)
+			client.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+		}
+	}
+
+	// JSON documents are valid YAML ones, and byte slices are encoded in base64 as required.
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "Opaque",
+		"metadata": map[string]string{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"data": data,
+	})
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s?fieldManager=dynsubst&force=true",
+		strings.TrimSuffix(server, "/"), url.PathEscape(namespace), url.PathEscape(name))
+	req, err := http.NewRequest(http.MethodPatch, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/apply-patch+yaml")
+	if token != nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil || status.Message == "" {
+			return fmt.Errorf("applying secret \"%s/%s\": %s", namespace, name, resp.Status)
+		}
+		return fmt.Errorf("applying secret \"%s/%s\": %s", namespace, name, status.Message)
+	}
+
+	return nil
+}
@@ -2,23 +2,73 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
-	table, profile, region string
-	inplace, help          bool
-	sess                   *session.Session
+	table, profile, region  string
+	prefix, envSuffix       string
+	engine, pluginDir       string
+	format, outputFile      string
+	roleARN, externalID     string
+	mfaToken, regions       string
+	accessKey, secretKey    string
+	sessionToken            string
+	noSharedConfig          bool
+	useFIPS, useDualStack   bool
+	versionAttr, ttlAttr    string
+	ignoreCase              bool
+	inplace, jsonMode, help bool
+	cfnMode, recursive      bool
+	prefetchMode, envsubst  bool
+	scanThreshold           int
+	preflightMode           bool
+	native                  bool
+	maxDepth                int
+	sess                    *session.Session
+	tables                  = tableFlag{}
+	tableList               string
+	paths                   pathFlag
+
+	// Tables supplied with -tables, in order of preference.
+	fallbackTables []string
+
+	// Keys being resolved recursively, outermost first.
+	chain []string
+	// Directory of the template being rendered.
+	templateDir = "."
+
+	// Keys of every table listed so far.
+	keyListings = map[string][]string{}
+	// Descriptions of every table described so far.
+	tableDescriptions = map[string]*dynamodb.TableDescription{}
+
+	errNotFound = errors.New("0 occurrences found")
+
+	// Matches placeholders.
+	placeholderRe = regexp.MustCompile(`{{(\w+?:)?.+?}}`)
 )
 
 const (
@@ -34,12 +84,40 @@ const (
 	// This option allows entries for different tables to be replaced in the same file.
 	// It can be used along with any amount of modifiers such as: "{{SKIP:DECRYPT:Password}}".
 	// Ex.: cat project.json | dynsubst project-settings | dynsubst project-credentials
+	// Keys can also be looked up in several tables in a single pass with -tables.
 	modSkip = "SKIP"
+	// Replace with the contents of another template file after rendering it.
+	// Relative paths are resolved from the directory of the template being rendered.
+	// Ex.: "{{INCLUDE:partials/header.conf}}"
+	modInclude = "INCLUDE"
+	// Replace with the values of every key matching a pattern, joined with a separator.
+	// The separator is delimited by the first colon following the modifier.
+	// Ex.: "{{JOIN:,:app/zones/*}}"
+	modJoin = "JOIN"
+	// Retrieve value from the table specified instead of the one supplied as an argument.
+	// This allows a single pass to replace entries from different tables in the same file.
+	// Ex.: "{{TABLE=network-settings:VpcId}}"
+	modTable = "TABLE"
+	// Retrieve value from the table in the region specified instead of the one supplied with -r.
+	// Ex.: "{{REGION=us-east-1:CertificateArn}}"
+	modRegion = "REGION"
+	// Only consider the items of the key meeting the conditions, as when several items share it.
+	// Ex.: "{{FILTER=Active=true:DBHost}}"
+	modFilter = "FILTER"
 
 	helpMsg = `
 Replace placeholders for their value in an AWS DynamoDB table.
 Any key in between braces ("{{Key}}") is considered a placeholder.
-Input can be supplied either from the standard input or from a file.
+Input can be supplied either from the standard input or from files, "-" standing for the standard input.
+Templates can also be retrieved from "https://" and "s3://bucket/key" URLs, cached by ETag with -cache.
+Several templates are rendered in order and their outputs concatenated, unless edited in place with -i.
+With -json, the keys supplied as arguments are printed as a JSON object instead.
+
+The "entrypoint" command is designed to run as PID 1 in a container.
+It renders the templates declared in the environment and then runs the command:
+
+  DYNSUBST_TABLE=project-settings
+  DYNSUBST_TEMPLATE_1=/etc/app/app.conf.tpl:/etc/app/app.conf
 
 Placeholders accept the following modifiers:
 
@@ -53,15 +131,194 @@ Placeholders accept the following modifiers:
   Will be replaced by the value of the "Key" key from AWS DynamoDB decrypted with AWS KMS.
   Example: "{{DECRYPT:Password}}" will be replaced by the decrypted value of the "Password" key.
 
+  {{B64:Key}} or {{BASE64:Key}}
+  Will be replaced by the value of the "Key" key from AWS DynamoDB encoded in base64.
+  Example: "{{B64:DECRYPT:Password}}" will be replaced by the decrypted value of "Password" in base64.
+
+  {{JSONESCAPE:Key}}
+  Will be replaced by the value of the "Key" key escaped to be embedded in a JSON string.
+  Example: "{{JSONESCAPE:Certificate}}" will have its quotes, backslashes and newlines escaped.
+
+  {{INDENT=N:Key}}
+  Will be replaced by the value of the "Key" key with every line but the first indented by N spaces.
+  Example: "{{INDENT=6:DECRYPT:TlsKey}}" can be placed at column 6 of a YAML block scalar.
+
+  {{URLENCODE:Key}}
+  Will be replaced by the value of the "Key" key percent-encoded to be used in URLs.
+  Example: "{{URLENCODE:DECRYPT:DBPass}}" can be used as the password of a connection string.
+
+  {{SHELLQUOTE:Key}}
+  Will be replaced by the value of the "Key" key single-quoted for POSIX shells.
+  Example: "{{SHELLQUOTE:Motd}}" will be replaced by "'It'\''s alive'".
+
+  {{UPPER:Key}}, {{LOWER:Key}}, {{TRIM:Key}}
+  Will be replaced by the value of the "Key" key in uppercase, in lowercase or without surrounding whitespace.
+  Example: "{{UPPER:TRIM:Environment}}" will be replaced by "PROD" when the value is " prod\n".
+
+  {{SHA256:Key}}, {{MD5:Key}}, {{CRC32:Key}}
+  Will be replaced by the hexadecimal digest of the value of the "Key" key.
+  Example: "{{SHA256:DECRYPT:Password}}" can be used as a cache-busting token.
+
+  {{HEX:Key}}
+  Will be replaced by the value of the "Key" key encoded in hexadecimal.
+  Example: "{{HEX:DECRYPT:PreSharedKey}}" will be replaced by the decrypted key in hexadecimal.
+
   {{SKIP:Key}}
   Will be replaced by the same placeholder after stripping the "SKIP" modifier.
   Example: "{{SKIP:DECRYPT:Password}}" will be replaced by "{{DECRYPT:Password}}".
+
+  {{TABLE=Table:Key}}
+  Will be replaced by the value of the "Key" key from the specified AWS DynamoDB table.
+  Example: "{{TABLE=network-settings:VpcId}}" will be replaced by the value of "VpcId" in "network-settings".
+
+  {{REGION=Region:Key}}
+  Will be replaced by the value of the "Key" key from the table in the specified AWS region.
+  Example: "{{REGION=us-east-1:CertificateArn}}" will be replaced by the value of "CertificateArn" in "us-east-1".
+
+  {{FILTER=Conditions:Key}}
+  Will be replaced by the value of the only item of the "Key" key meeting the comma-separated conditions.
+  Conditions have the form "Attribute=value" or "Attribute!=value", as with -filter.
+  Example: "{{FILTER=Active=true:DBHost}}" will be replaced by the value of the active "DBHost" item.
+
+  {{JOIN:Separator:Pattern}}
+  Will be replaced by the values of every key matching the pattern, joined with the separator.
+  Example: "{{JOIN:,:app/zones/*}}" will be replaced by "eu-west-1a,eu-west-1b".
+
+  {{INCLUDE:Path}}
+  Will be replaced by the rendered contents of the file, relative to the current template.
+  Example: "{{INCLUDE:partials/header.conf}}" will be replaced by the rendered header.
+
+The following placeholders are replaced by metadata about the run:
+
+  {{@timestamp}}         Time at which the run started, in RFC 3339 format.
+  {{@table}}             Table supplied as an argument.
+  {{@caller-arn}}        ARN of the identity used to call AWS.
+  {{@dynsubst-version}}  Version of dynsubst.
+
+Keys can be pinned to a version stored in the attribute specified with -version-attr:
+
+  {{Key@Version}}
+  Will be replaced by the value of the item of the "Key" key with the specified version.
+  Example: "{{Timeout@3}}" will be replaced by the value of version 3 of the "Timeout" key.
+
+Tables supplied with "-table alias=table" can be referred to by their alias:
+
+  {{Alias:Key}}
+  Will be replaced by the value of the "Key" key from the table with the specified alias.
+  Example: "{{net:VpcId}}" will be replaced by the value of "VpcId" in the table aliased as "net".
+
+Accounts supplied with "-account alias=profile" or "-account alias=role-arn" can be referred to
+by their alias as well, so that a single template gathers values from several accounts:
+
+  {{Alias:Key}}
+  Will be replaced by the value of the "Key" key from the table in the account with the specified alias.
+  Example: "{{billing:net:VpcId}}" will be replaced by the value of "VpcId" in the table aliased as "net"
+  in the account aliased as "billing".
+
+Modifiers can be chained. They are applied from the key outwards, so the modifier closest
+to the key is applied first, reading like nested function calls: "{{BASE64:TRIM:DECRYPT:Key}}"
+decrypts the value, trims it and then encodes it in base64. Unknown modifiers in uppercase
+are reported as errors, so keys containing colons must be prefixed with the "GET" modifier.
+Example: "{{TABLE=credentials:DECRYPT:Password}}" will be replaced by the decrypted value of
+the "Password" key in the "credentials" table.
+
+Sections can be included depending on the presence or the value of a key:
+
+  {{#IF Key}}...{{/IF}}
+  Will be replaced by its contents when the key exists and its value is neither empty nor "false".
+
+  {{#IFEQ Key Value}}...{{/IF}}
+  Will be replaced by its contents when the value of the key is equal to "Value".
+  Example: "{{#IFEQ Environment prod}}debug = false{{/IF}}".
+
+Sections can also be repeated for every key matching a pattern:
+
+  {{#EACH Pattern}}...{{/EACH}}
+  Will be replaced by its contents once per matching key, in order.
+  Inside the section, "{{.Key}}" and "{{.Value}}" refer to the current key and its value.
+  Example: "{{#EACH app/hosts/*}}server {{.Value}};{{/EACH}}".
+
+Plugins in the plugin directory (-plugin-dir) are available as modifiers named after them in uppercase.
+Plugins can either be executables or sandboxed WebAssembly modules with the ".wasm" extension.
+Executables can also provide values instead of AWS DynamoDB:
+
+  {{PLUGIN=Name:Key}}
+  Will be replaced by the value of the "Key" key retrieved by the "Name" plugin.
+  Example: "{{PLUGIN=vault:secret/db}}" will be replaced by the value provided by the "vault" plugin.
+
+With "-format json", the input is parsed as JSON and placeholders are only replaced inside string values,
+escaping the values as needed. With -native, string values consisting of a single placeholder are replaced
+by the value it resolves to when it is valid JSON, such as a number, a boolean or an object.
+Example: "{"port": "{{Port}}"}" will be replaced by "{"port": 5432}".
+
+With "-format yaml", the input is parsed as YAML and placeholders are only replaced inside scalar values,
+quoting the values as needed and emitting multi-line values as block scalars. Comments are preserved,
+although documents are re-emitted with an indentation of two spaces. -native is also supported.
+
+In both formats, substitution can be restricted to values at the paths supplied with -path,
+written as in jq: ".spec.template.spec.containers[*].env[*].value".
+
+With "-format toml", values are escaped inside TOML strings and emitted as strings outside of them,
+unless running with -native. With "-format ini", placeholders are only replaced inside values,
+which are quoted when needed. In both formats, placeholders in comments are left as is.
+
+With "-format systemd-env", placeholders are only replaced inside values of environment files read with
+EnvironmentFile=, or of "Environment=KEY=value" assignments in units, quoting and escaping them for systemd.
+With "-format properties", placeholders are only replaced inside values of Java properties files,
+escaping separators, line breaks, leading whitespace and characters outside of ASCII.
+With "-format xml", values are escaped as entities, or kept inside CDATA sections, so that documents
+remain well-formed. Placeholders in comments are left as is.
+
+With "-engine gotemplate", templates are parsed with Go's text/template instead,
+and values are retrieved with the "get", "decrypt" and "secret" functions:
+
+  {{ get "Username" }}
+  {{ get "Password" | decrypt }}
+  {{ if eq (get "Environment") "prod" }}{{ secret "Password" }}{{ end }}
+
+The following utility functions are also available, as in the Sprig library:
+default, ternary, toJson, b64enc, indent and trim.
+
+With -envsubst, references to environment variables ("$VAR" or "${VAR}") are also expanded,
+so that templates do not need to be processed by envsubst separately.
+
+With -recursive, placeholders found in values retrieved from AWS DynamoDB are also replaced.
+Example: "postgres://{{DBUser}}:{{DECRYPT:DBPass}}@{{DBHost}}" can be stored as a single value.
+
+Templates can configure how they are rendered with directives in their first lines, which are removed from the output:
+  #dynsubst: table=app-settings region=eu-west-1 format=yaml
+Directives can also be declared in a sidecar file named after the template, such as "config.json.dynsubst".
+Directives take precedence over flags and only apply to the template declaring them.
+Remote templates cannot include files nor declare the "output", "engine", "profile", "envsubst"
+and "recursive" directives.
+
+Templates can be made executable when dynsubst is also installed as dynsubst-run, in which case
+they are rendered to standard output or to the file specified with the "output" directive:
+  #!/usr/bin/env dynsubst-run
+  #dynsubst: table=app-settings output=/etc/app/config.yaml
 `
 )
 
 func init() {
 	flag.Usage = func() {
-		fmt.Println("Usage: dynsubst [flags] table [file]")
+		fmt.Println("Usage: dynsubst [flags] table [file...]")
+		fmt.Println("       dynsubst [flags] -table [alias=]table... [file...]")
+		fmt.Println("       dynsubst -json [flags] table key...")
+		fmt.Println("       dynsubst [flags] entrypoint command [args...]")
+		fmt.Println("       dynsubst [flags] bench table [-placeholders n] [-unique n] [-transact-sizes n,...] [-scan-thresholds n,...]")
+		fmt.Println("       dynsubst -cache file cache ls|purge|stats [table [key...]]")
+		fmt.Println("       dynsubst -cache file warm table [-prefix prefix]")
+		fmt.Println("       dynsubst [flags] doctor table")
+		fmt.Println("       dynsubst [flags] iam-policy table file...")
+		fmt.Println("       dynsubst [flags] usage [-format csv|json] path...")
+		fmt.Println("       dynsubst [flags] graph table file...")
+		fmt.Println("       dynsubst [flags] reverse table file [-min-length n]")
+		fmt.Println("       dynsubst [flags] drift table template file [-q]")
+		fmt.Println("       dynsubst [flags] apply manifest")
+		fmt.Println("       dynsubst -table table [flags] k8s-secret -name name [-namespace namespace] -from [key=]file... -from-env-file file...")
+		fmt.Println("       dynsubst [flags] push-ssm table [-prefix prefix] [-key-id key]")
+		fmt.Println("       dynsubst [flags] dotenv table [-prefix prefix] [[NAME=]key...]")
+		fmt.Println("       dynsubst-run file")
 		flag.PrintDefaults()
 		if help {
 			fmt.Println(helpMsg)
@@ -69,7 +326,67 @@ func init() {
 	}
 	flag.StringVar(&profile, "p", "default", "specify AWS profile")
 	flag.StringVar(&region, "r", "", "specify AWS region")
+	flag.StringVar(&proxyURL, "proxy", "", "specify proxy URL for AWS requests (default: as in HTTPS_PROXY)")
+	flag.DurationVar(&connectTimeout, "connect-timeout", 10*time.Second, "specify timeout of connections to AWS")
+	flag.DurationVar(&requestTimeout, "timeout", 0, "specify timeout of each attempt of AWS requests (default: none)")
+	flag.DurationVar(&keepAlive, "keep-alive", 30*time.Second, "specify keep-alive interval of connections to AWS (negative to disable keep-alives)")
+	flag.StringVar(&caBundle, "ca-bundle", "", "specify file of PEM certificates to trust instead of the system ones, as for TLS-intercepting proxies")
+	flag.StringVar(&tlsMinVersion, "tls-min-version", "1.2", "specify minimum TLS version: \"1.0\", \"1.1\", \"1.2\" or \"1.3\"")
+	flag.BoolVar(&useFIPS, "use-fips", false, "use FIPS 140-2 endpoints of AWS services, as required in GovCloud")
+	flag.BoolVar(&useDualStack, "use-dualstack", false, "use dual-stack endpoints of AWS services, as required in IPv6-only networks")
+	flag.StringVar(&cacheFile, "cache", "", "keep last known good values in file, such as \"~/.dynsubst/cache.json\", to use them when AWS is unavailable")
+	flag.BoolVar(&cacheDecrypted, "cache-decrypted", false, "also cache decrypted values, encrypted with the key in "+envCacheKey+" or in the \".key\" file next to the cache")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "use cached values retrieved within the duration without looking them up again (default: only when AWS is unavailable, never with -verify)")
+	flag.BoolVar(&noStale, "no-stale", false, "fail instead of using cached values when AWS is unavailable")
+	flag.StringVar(&regions, "regions", "", "specify comma-separated AWS regions to fail over to in order, as for global tables")
+	flag.StringVar(&roleARN, "role-arn", "", "specify ARN of an AWS IAM role to assume")
+	flag.StringVar(&externalID, "external-id", "", "specify external ID required to assume the role")
+	flag.StringVar(&accessKey, "access-key", "", "specify AWS access key ID, overriding the credentials of the profile")
+	flag.StringVar(&secretKey, "secret-key", "", "specify AWS secret access key")
+	flag.StringVar(&sessionToken, "session-token", "", "specify AWS session token of temporary credentials")
+	flag.BoolVar(&noSharedConfig, "no-shared-config", false, "ignore shared AWS configuration files, using credentials from the environment or flags")
+	flag.StringVar(&mfaToken, "mfa-token", "", "specify MFA token code for profiles requiring MFA (default: prompt for it)")
 	flag.BoolVar(&inplace, "i", false, "edit file in place")
+	flag.StringVar(&outputFile, "output", "", "write output to file, \"s3://bucket/key\" URL or \"secretsmanager://secret\" instead of standard output")
+	flag.StringVar(&sseMode, "sse", "", "encrypt output written to AWS S3 server-side with \"aws:kms\" or \"AES256\"")
+	flag.StringVar(&sseKMSKeyID, "sse-kms-key-id", "", "specify AWS KMS key of server-side encryption with -sse aws:kms (default: the AWS managed key)")
+	flag.StringVar(&execOnChange, "exec-on-change", "", "run the shell command after writing files, only if their contents changed")
+	flag.StringVar(&stampPrefix, "stamp", "", "stamp output with a hash of the template and its values in a comment starting with the prefix, such as \"#\", skipping files already stamped with it")
+	flag.Var(tables, "table", "specify AWS DynamoDB table, optionally as \"alias=table\" (can be repeated)")
+	flag.StringVar(&tableList, "tables", "", "specify comma-separated AWS DynamoDB tables to look keys up in, in order, instead of a single table")
+	flag.Var(accounts, "account", "specify AWS account as \"alias=profile\" or \"alias=role-arn\" (can be repeated)")
+	flag.StringVar(&prefix, "prefix", "", "prepend prefix to every key before looking it up")
+	flag.Var(&onlyKeys, "only", "only replace placeholders of keys matching the pattern, such as \"app/*\" (can be repeated)")
+	flag.Var(&ignoredKeys, "ignore", "leave placeholders of keys matching the pattern intact (can be repeated)")
+	flag.StringVar(&envSuffix, "env-suffix", "", "look up keys with the \".suffix\" suffix first, falling back to keys without it")
+	flag.StringVar(&versionAttr, "version-attr", "Version", "specify numeric attribute (or sort key) holding item versions")
+	flag.StringVar(&ttlAttr, "ttl-attr", "", "specify TTL attribute of expired items to ignore (default: as configured in the table)")
+	flag.StringVar(&filter, "filter", "", "only consider items meeting comma-separated conditions such as \"Active=true,Stage!=draft\"")
+	flag.StringVar(&verifyKey, "verify", "", "refuse values without a valid signature by the AWS KMS key in their \""+signatureAttr+"\" attribute")
+	flag.StringVar(&signingAlgorithm, "signing-algorithm", kms.SigningAlgorithmSpecRsassaPssSha256, "specify algorithm of the signatures verified with -verify")
+	flag.StringVar(&manifestFile, "manifest", "", "write a manifest of the render to file, with the digest of the output, the keys used and the caller")
+	flag.StringVar(&manifestKey, "manifest-key", "", "sign the manifest with the AWS KMS key, using the algorithm specified with -signing-algorithm")
+	flag.BoolVar(&ignoreCase, "ignore-case", false, "match keys case-insensitively (requires scanning the table)")
+	flag.BoolVar(&prefetchMode, "prefetch", false, "fetch every referenced key in transactions before replacing, reading up to 100 keys at the same point in time")
+	flag.StringVar(&templateCheck, "validate-template", "", "check templates before rendering them with placeholders stubbed, as \"json\", \"yaml\", \"xml\" or with a shell command on the file \"%s\"")
+	flag.BoolVar(&preflightMode, "preflight", false, "check that every permission required by the template is granted before rendering it")
+	flag.IntVar(&scanThreshold, "scan-threshold", 0, "scan tables with more referenced keys than the threshold instead of looking keys up one by one (default: never)")
+	flag.StringVar(&engine, "engine", engineDynsubst, "specify template engine: \"dynsubst\" or \"gotemplate\"")
+	flag.StringVar(&format, "format", formatText, "specify format of the input: \"text\", \"json\", \"yaml\", \"toml\", \"ini\", \"systemd-env\", \"properties\" or \"xml\"")
+	flag.Var(&paths, "path", "restrict substitution in JSON and YAML to values at the path, such as \".spec.containers[*].image\" (can be repeated)")
+	flag.BoolVar(&native, "native", false, "replace JSON or YAML strings consisting of a single placeholder by the value it resolves to, and TOML placeholders outside strings by raw values")
+	flag.StringVar(&pluginDir, "plugin-dir", defaultPluginDir(), "specify directory of plugins")
+	flag.BoolVar(&envsubst, "envsubst", false, "expand references to environment variables in templates")
+	flag.BoolVar(&jsonMode, "json", false, "print the values of the keys supplied as arguments as a JSON object")
+	flag.BoolVar(&recursive, "recursive", false, "resolve placeholders found in values")
+	flag.IntVar(&maxDepth, "max-depth", 10, "maximum depth of recursive resolution and includes")
+	flag.BoolVar(&cfnMode, "cfn", false, "run as an AWS Lambda handler for CloudFormation custom resources")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "export OpenTelemetry spans of renders, placeholders and AWS requests to the OTLP/HTTP collector at \"host:port\"")
+	flag.StringVar(&statsdAddr, "statsd", "", "send metrics of the run to the StatsD server at \"host:port\"")
+	flag.BoolVar(&emf, "emf", false, "print metrics of the run to standard error in the CloudWatch embedded metric format")
+	flag.BoolVar(&printStats, "stats", false, "print a summary of the run to standard error")
+	flag.BoolVar(&verbose, "v", false, "log placeholders as they are resolved")
+	flag.BoolVar(&veryVerbose, "vv", false, "log placeholders and AWS requests, including their request IDs")
 	flag.BoolVar(&help, "h", false, "show extended help")
 }
 
@@ -78,97 +395,770 @@ func main() {
 
 	flag.Parse()
 	args := flag.Args()
-	if len(args) < 1 {
+	if len(args) < 1 && !cfnMode && len(tables) == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	table = args[0]
-	var file string
-	if len(args) > 1 {
-		file = args[1]
+	if err := setRegions(regions); err != nil {
+		log.Fatal(err)
+	}
+	sess, err = newSession()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadPlugins(pluginDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := startTracing(); err != nil {
+		log.Fatal(err)
+	}
+	defer stopTracing()
+
+	if cfnMode {
+		startCFN()
+		return
+	}
+
+	handleInterrupts()
+
+	if err := loadCache(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Tables to fall back to are set before running subcommands, as the access required depends on them.
+	if tableList != "" {
+		if tables[""] != "" {
+			log.Fatal("-tables cannot be used along with -table without an alias")
+		}
+		fallbackTables = strings.Split(tableList, ",")
+		tables[""] = fallbackTables[0]
+	}
+
+	if len(args) > 0 && args[0] == "entrypoint" {
+		runEntrypoint(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "bench" {
+		runBench(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "cache" {
+		runCache(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "warm" {
+		runWarm(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "doctor" {
+		runDoctor(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "iam-policy" {
+		runIAMPolicy(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "usage" {
+		runUsage(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "graph" {
+		runGraph(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "reverse" {
+		runReverse(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "drift" {
+		runDrift(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "apply" {
+		runApply(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "k8s-secret" {
+		runK8sSecret(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "push-ssm" {
+		runPushSSM(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "dotenv" {
+		runDotenv(args[1:])
+		return
+	}
+
+	switch {
+	case filepath.Base(os.Args[0]) == runName:
+		// The table is declared by the template.
+	case len(tables) == 0:
+		table, args = args[0], args[1:]
+	default:
+		table = tables[""]
+	}
+	if jsonMode {
+		printJSON(args)
+		return
+	}
+
+	// Templates are read from the files in order, "-" standing for standard input.
+	files := args
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	if inplace && manifestFile != "" && len(files) > 1 {
+		log.Fatal("-manifest cannot describe several files edited in place")
+	}
+
+	// Templates edited in place are written to themselves, while the rest are concatenated in order.
+	var edited []renderedFile
+	var templates, outputs []string
+	for _, file := range files {
+		var r renderedFile
+		r, err = renderSource(file, len(files) > 1)
+		if err != nil {
+			break
+		}
+		if inplace && r.file != "" && !isRemote(r.file) {
+			r.output, r.upToDate = stampOutput(r.file, r.text, r.output)
+			edited = append(edited, r)
+		} else {
+			templates = append(templates, r.text)
+			outputs = append(outputs, r.output)
+		}
+	}
+	if err := saveCache(); err != nil {
+		log.Printf("warning: error saving cache: %v", err)
+	}
+	emitMetrics()
+	if printStats {
+		log.Print(stats)
+	}
+	if err != nil {
+		log.Fatal(withSuggestions(err))
+	}
+
+	var changed bool
+	for _, r := range edited {
+		if r.upToDate || unchanged(r.file, []byte(r.output)) {
+			if verbose || veryVerbose {
+				log.Printf("%s is up to date", r.file)
+			}
+		} else if err := writeFile(r.file, []byte(r.output), 0); err != nil {
+			log.Fatal(err)
+		} else {
+			changed = true
+		}
+		if err := writeManifest(r.file, r.output); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(outputs) == 0 {
+		runOnChangeIf(changed)
+		return
 	}
 
+	output, upToDate := stampOutput(outputFile, strings.Join(templates, ""), strings.Join(outputs, ""))
+	if upToDate || (outputFile != "" && unchanged(outputFile, []byte(output))) {
+		if verbose || veryVerbose {
+			log.Printf("%s is up to date", outputFile)
+		}
+	} else if strings.HasPrefix(outputFile, "s3://") {
+		if err := writeS3(outputFile, []byte(output)); err != nil {
+			log.Fatal(err)
+		}
+	} else if strings.HasPrefix(outputFile, secretsManagerScheme) {
+		if err := writeSecret(outputFile, output); err != nil {
+			log.Fatal(err)
+		}
+	} else if outputFile != "" {
+		err := writeFile(outputFile, []byte(output), 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		changed = true
+	} else {
+		fmt.Println(output)
+	}
+
+	// The manifest describes the file written, or standard output with its trailing newline.
+	if outputFile != "" {
+		err = writeManifest(outputFile, output)
+	} else {
+		err = writeManifest("", output+"\n")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	runOnChangeIf(changed)
+}
+
+// A template rendered from a source supplied as an argument.
+type renderedFile struct {
+	// File the template was read from, which is empty for standard input.
+	file         string
+	text, output string
+	// Whether the file is already stamped as up to date with -stamp.
+	upToDate bool
+}
+
+// Renders the template read from the file, from standard input for "-" or from a "https://" or "s3://" URL.
+// When rendering several templates, the directives of each one only apply to itself.
+func renderSource(file string, several bool) (renderedFile, error) {
 	var text string
-	if file == "" {
+	if file == "-" {
+		file = ""
 		input, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
-			log.Fatal(err)
+			return renderedFile{}, err
+		}
+		text = string(input)
+		templateDir = "."
+	} else if isRemote(file) {
+		input, err := readRemote(file)
+		if err != nil {
+			return renderedFile{}, err
 		}
 		text = string(input)
+		templateDir = "."
 	} else {
 		input, err := ioutil.ReadFile(file)
 		if err != nil {
-			log.Fatal(err)
+			return renderedFile{}, err
 		}
 		text = string(input)
+		templateDir = filepath.Dir(file)
+	}
+
+	remoteTemplate = isRemote(file)
+	if several {
+		defer saveDirectives()()
+	}
+	text, err := applyDirectives(file, text)
+	if err != nil {
+		return renderedFile{}, err
+	}
+	if err := validateTemplate(text); err != nil {
+		return renderedFile{}, err
+	}
+
+	if preflightMode {
+		if err := preflight(file, text); err != nil {
+			return renderedFile{}, err
+		}
+	}
+
+	if prefetchMode || scanThreshold > 0 {
+		if err := prefetch(text); err != nil {
+			return renderedFile{}, err
+		}
 	}
 
-	awsConfig := aws.NewConfig()
+	end := startSpan("render", attribute.String("file", file))
+	startProgress(text)
+	output, err := renderTemplate(text)
+	endProgress()
+	end(err)
+
+	return renderedFile{file: file, text: text, output: output}, err
+}
+
+// Returns a session for the profile and region supplied with -p and -r,
+// assuming the role supplied with -role-arn if any.
+func newSession() (*session.Session, error) {
+	if externalID != "" && roleARN == "" {
+		return nil, fmt.Errorf("-external-id requires -role-arn")
+	}
+
+	if (accessKey == "") != (secretKey == "") {
+		return nil, fmt.Errorf("-access-key and -secret-key must be specified together")
+	}
+
+	if err := waitWebIdentityToken(); err != nil {
+		return nil, err
+	}
+
+	awsConfig, err := newConfig()
+	if err != nil {
+		return nil, err
+	}
+	if accessKey != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(accessKey, secretKey, sessionToken))
+	}
+	// Force usage of shared AWS configuration unless disabled, as in ephemeral CI runners.
+	sharedConfig := session.SharedConfigEnable
+	if noSharedConfig {
+		sharedConfig = session.SharedConfigDisable
+	}
+	s, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		Profile:           profile,
+		SharedConfigState: sharedConfig,
+		// Only called for profiles declaring "mfa_serial".
+		// The resulting credentials are cached by the session for the rest of the run.
+		AssumeRoleTokenProvider: mfaTokenProvider,
+	})
+	if err != nil {
+		return nil, err
+	}
+	addHandlers(s)
+	if roleARN == "" {
+		return s, nil
+	}
+
+	creds := stscreds.NewCredentials(s, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		if externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+	})
+	return s.Copy(aws.NewConfig().WithCredentials(creds)), nil
+}
+
+// Returns the configuration shared by every session, as specified with flags.
+func newConfig() (*aws.Config, error) {
+	client, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+	awsConfig := aws.NewConfig().WithHTTPClient(client)
 	if region != "" {
 		awsConfig = awsConfig.WithRegion(region)
 	}
-	sess, err = session.NewSessionWithOptions(session.Options{
-		Config:  *awsConfig,
-		Profile: profile,
-		// Force usage of shared AWS configuration.
-		SharedConfigState: session.SharedConfigEnable,
-	})
+	if useFIPS {
+		awsConfig.UseFIPSEndpoint = endpoints.FIPSEndpointStateEnabled
+	}
+	if useDualStack {
+		awsConfig.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+
+	return awsConfig, nil
+}
+
+// Tables supplied with the -table flag, indexed by their alias.
+// The table supplied without an alias is used for placeholders without one.
+type tableFlag map[string]string
+
+func (t tableFlag) String() string {
+	var tables []string
+	for alias, name := range t {
+		if alias == "" {
+			tables = append(tables, name)
+		} else {
+			tables = append(tables, fmt.Sprintf("%s=%s", alias, name))
+		}
+	}
+	sort.Strings(tables)
+
+	return strings.Join(tables, ",")
+}
+
+func (t tableFlag) Set(value string) error {
+	alias, name := "", value
+	if i := strings.Index(value, "="); i >= 0 {
+		alias, name = value[:i], value[i+1:]
+	}
+	if _, ok := t[alias]; ok {
+		return fmt.Errorf("table already specified for alias \"%s\"", alias)
+	}
+	t[alias] = name
+
+	return nil
+}
+
+// Prints a JSON object mapping each of the supplied keys to its value.
+// Keys accept the same modifiers as placeholders, so that the output can be
+// consumed directly from tools such as Ansible without parsing free-form text.
+// Ex.: dynsubst -json project-settings Username DECRYPT:Password
+func printJSON(keys []string) {
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, err := resolve(fmt.Sprintf("{{%s}}", key))
+		if err != nil {
+			log.Fatal(withSuggestions(err))
+		}
+		values[key] = value
+	}
+
+	output, err := json.Marshal(values)
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println(string(output))
+}
+
+// Returns the template rendered with the engine selected with -engine.
+// Formats other than text are only supported by the default engine.
+func renderTemplate(text string) (string, error) {
+	text = expandEnv(text)
+	switch engine {
+	case engineDynsubst:
+	case engineGoTemplate:
+		if format != formatText {
+			return "", fmt.Errorf("format \"%s\" is not supported by engine \"%s\"", format, engine)
+		}
+		return renderGoTemplate(text)
+	default:
+		return "", fmt.Errorf("unknown engine \"%s\"", engine)
+	}
+
+	if len(paths) > 0 && format != formatJSON && format != formatYAML {
+		return "", fmt.Errorf("-path is only supported by the \"json\" and \"yaml\" formats")
+	}
+
+	switch format {
+	case formatText:
+		return render(text)
+	case formatJSON:
+		return renderJSON(text)
+	case formatYAML:
+		return renderYAML(text)
+	case formatTOML:
+		return renderTOML(text)
+	case formatINI:
+		return renderINI(text)
+	case formatSystemdEnv:
+		return renderSystemdEnv(text)
+	case formatProperties:
+		return renderProperties(text)
+	case formatXML:
+		return renderXML(text)
+	}
+
+	return "", fmt.Errorf("unknown format \"%s\"", format)
+}
 
-	re := regexp.MustCompile(`{{(\w+?:)?.+?}}`)
-	output := re.ReplaceAllStringFunc(text, replaceFunc)
+// Returns the text after expanding every block and replacing every placeholder, stopping at the first error.
+func render(text string) (string, error) {
+	var b strings.Builder
+	for {
+		loc := blockRe.FindStringSubmatchIndex(text)
+		if loc == nil {
+			break
+		}
 
-	if inplace && file != "" {
-		err := ioutil.WriteFile(file, []byte(output), 0)
+		output, err := renderPlaceholders(text[:loc[0]])
 		if err != nil {
-			log.Fatal(err)
+			return "", err
 		}
-	} else {
-		fmt.Println(output)
+		b.WriteString(output)
+
+		output, rest, err := renderBlock(text, loc, render)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(output)
+		text = rest
 	}
+
+	output, err := renderPlaceholders(text)
+	if err != nil {
+		return "", err
+	}
+	b.WriteString(output)
+
+	return b.String(), nil
 }
 
-func replaceFunc(input string) string {
+// Returns the text after replacing every placeholder, stopping at the first error.
+func renderPlaceholders(text string) (string, error) {
 	var err error
 
-	re := regexp.MustCompile(`{{((?P<mod>\w+?):)?(?P<key>.+?)}}`)
-	matches := re.FindStringSubmatch(input)
-
-	var repl, mod string
-	for i, name := range re.SubexpNames() {
-		switch name {
-		case "mod":
-			mod = matches[i]
-		case "key":
-			if mod == modSkip {
-				return fmt.Sprintf("{{%s}}", matches[i])
-			} else {
-				repl, err = dynamodbQuery(table, matches[i])
-				if err != nil {
-					log.Fatal(err)
-					return ""
-				}
-			}
+	output := placeholderRe.ReplaceAllStringFunc(text, func(input string) string {
+		if err != nil {
+			return input
 		}
+		var repl string
+		repl, err = resolve(input)
+		return repl
+	})
+
+	return output, err
+}
+
+// Returns the replacement for a single placeholder, logging and tracing its resolution.
+func resolve(input string) (string, error) {
+	logPlaceholder(input)
+	end := startSpan("resolve", attribute.String("placeholder", input))
+	value, err := resolvePlaceholder(input)
+	end(err)
+	stepProgress()
+	if err != nil {
+		stats.failed++
+	} else {
+		stats.resolved++
+		recordStampInput(input, value)
 	}
 
-	if mod == modDecrypt {
-		repl, err = kmsDecrypt(repl)
+	return value, err
+}
+
+// Returns the replacement for a single placeholder.
+func resolvePlaceholder(input string) (string, error) {
+	p, err := parsePlaceholder(input)
+	if err != nil {
+		return "", err
+	}
+	if p.skipped != "" {
+		return p.skipped, nil
+	}
+	if p.source == "" && !selected(p.key) {
+		return input, nil
+	}
+
+	if p.account != "" {
+		restore, err := useAccount(p.account)
 		if err != nil {
-			log.Fatal(err)
-			return ""
+			return "", err
+		}
+		defer restore()
+	}
+	if p.region != "" {
+		defer useRegion(p.region)()
+	}
+	if p.filter != "" {
+		saved := currentFilter
+		currentFilter = p.filter
+		defer func() { currentFilter = saved }()
+	}
+
+	var value string
+	switch p.source {
+	case modInclude:
+		value, err = include(p.key)
+	case modJoin:
+		value, err = join(p.table, p.key)
+	case modPlugin:
+		value, err = pluginValue(p.sourceArg, p.key)
+	default:
+		value, err = resolveKey(input, p.table, p.key)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Modifiers are applied from the key outwards.
+	for i := len(p.modifiers) - 1; i >= 0; i-- {
+		m := p.modifiers[i]
+		value, err = modifiers[m.name](value, m.arg)
+		if err != nil {
+			return "", fmt.Errorf("error applying %s modifier: %w", m.name, err)
+		}
+	}
+
+	return value, nil
+}
+
+// Returns the value of the key of a placeholder before applying its modifiers.
+func resolveKey(input, table, key string) (string, error) {
+	if value, ok := itemField(key); ok {
+		return value, nil
+	}
+	if value, ok, err := metadata(key); ok {
+		return value, err
+	}
+
+	if table == "" {
+		return "", fmt.Errorf("no table specified for \"%s\"", input)
+	}
+	table, value, err := fetchFallback(table, key)
+	if err != nil {
+		return "", err
+	}
+	usedKeys[table+":"+key] = true
+	if recursive {
+		return renderRecursive(key, value)
+	}
+
+	return value, nil
+}
+
+// Renders the value of a key, failing on reference cycles or when exceeding the maximum depth.
+func renderRecursive(key, value string) (string, error) {
+	for _, k := range chain {
+		if k == key {
+			return "", fmt.Errorf("reference cycle found: %s", strings.Join(append(chain, key), " -> "))
+		}
+	}
+	if len(chain) >= maxDepth {
+		return "", fmt.Errorf("maximum depth of %d exceeded: %s", maxDepth, strings.Join(append(chain, key), " -> "))
+	}
+
+	chain = append(chain, key)
+	defer func() { chain = chain[:len(chain)-1] }()
+
+	return render(value)
+}
+
+// Returns the rendered contents of a template file.
+// Remote templates cannot include files, as whoever serves them could read any file of the host.
+func include(path string) (string, error) {
+	if remoteTemplate {
+		return "", fmt.Errorf("%s is not allowed in remote templates", modInclude)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(templateDir, path)
+	}
+	input, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer func(dir string) { templateDir = dir }(templateDir)
+	templateDir = filepath.Dir(path)
+
+	return renderRecursive(path, expandEnv(string(input)))
+}
+
+// Returns the template after expanding references to environment variables ("$VAR" or "${VAR}")
+// when running with -envsubst, as envsubst would.
+func expandEnv(text string) string {
+	if !envsubst {
+		return text
+	}
+
+	return os.ExpandEnv(text)
+}
+
+// Returns the values of the keys matching a pattern joined by a separator,
+// both specified as "separator:pattern".
+func join(table, input string) (string, error) {
+	args := strings.SplitN(input, ":", 2)
+	if len(args) != 2 {
+		return "", fmt.Errorf("invalid %s placeholder: expected separator and pattern", modJoin)
+	}
+
+	var items []item
+	err := failover(func() (err error) {
+		items, err = dynamodbScan(table, args[1])
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+
+	return strings.Join(values, args[0]), nil
+}
+
+// Returns the value of a key after applying the prefix.
+// When an environment suffix is set, the environment-specific variant of the key is preferred.
+// Ex.: with "-env-suffix prod", "Timeout.prod" is looked up before "Timeout".
+func fetch(table, key string) (string, error) {
+	key, version := splitVersion(key)
+
+	var err error
+	for _, k := range candidates(key) {
+		if ignoreCase {
+			k, err = matchCase(table, k)
+			if errors.Is(err, errNotFound) {
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if value, ok := freshValue(table, k, version); ok {
+			return value, nil
+		}
+
+		var value string
+		err = failover(func() (err error) {
+			value, err = dynamodbQuery(table, k, version)
+			return err
+		})
+		value, err = cacheResult(table, k, version, value, err)
+		if !errors.Is(err, errNotFound) {
+			return value, err
 		}
 	}
 
-	return repl
+	return "", &keyNotFoundError{table: table, key: prefix + key, sess: sess, err: err}
+}
+
+// Returns the table the key was found in and its value.
+// When the table is the first of those supplied with -tables, keys not found in it are looked up
+// in the following ones in turn.
+func fetchFallback(table, key string) (string, string, error) {
+	if len(fallbackTables) == 0 || table != fallbackTables[0] {
+		value, err := fetch(table, key)
+		return table, value, err
+	}
+
+	var err error
+	for _, t := range fallbackTables {
+		var value string
+		value, err = fetch(t, key)
+		if !errors.Is(err, errNotFound) {
+			return t, value, err
+		}
+	}
+
+	return table, "", err
+}
+
+// Returns the keys to look up for a key, in order of preference.
+func candidates(key string) []string {
+	keys := []string{prefix + key}
+	if envSuffix != "" {
+		keys = append([]string{fmt.Sprintf("%s%s.%s", prefix, key, envSuffix)}, keys...)
+	}
+
+	return keys
+}
+
+// Splits a key pinned to a version ("Key@3") into the key and the version.
+// Keys which are not pinned to a version are returned with an empty version.
+func splitVersion(key string) (string, string) {
+	i := strings.LastIndex(key, "@")
+	if i < 0 {
+		return key, ""
+	}
+	if _, err := strconv.ParseUint(key[i+1:], 10, 64); err != nil {
+		return key, ""
+	}
+
+	return key[:i], key[i+1:]
+}
+
+// Returns the key stored in the table which is equal to the one specified under case folding.
+func matchCase(table, key string) (string, error) {
+	keys, err := listKeys(table)
+	if err != nil {
+		return "", err
+	}
+
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return k, nil
+		}
+	}
+
+	return "", fmt.Errorf("error querying for \"%v\": %w", key, errNotFound)
 }
 
 // Returns the string value for the AWS DynamoDB attribute named "Value" for the key specified.
-func dynamodbQuery(table, key string) (string, error) {
-	svc := dynamodb.New(sess)
+// When a version is specified, only the item with that version is considered.
+func dynamodbQuery(table, key, version string) (string, error) {
+	if version == "" && activeFilter() == "" {
+		if value, ok := snapshotValue(table, key); ok {
+			stats.cached++
+			if value == nil {
+				return "", fmt.Errorf("error querying for \"%v\": %w", key, errNotFound)
+			}
+			return *value, nil
+		}
+	}
 
 	queryInput := &dynamodb.QueryInput{
 		TableName: aws.String(table),
@@ -185,20 +1175,185 @@ func dynamodbQuery(table, key string) (string, error) {
 		},
 	}
 
-	resp, err := svc.Query(queryInput)
+	if version != "" {
+		condition := &dynamodb.Condition{
+			ComparisonOperator: aws.String("EQ"),
+			AttributeValueList: []*dynamodb.AttributeValue{
+				{
+					N: aws.String(version),
+				},
+			},
+		}
+		sortKey, err := isSortKey(table, versionAttr)
+		if err != nil {
+			return "", err
+		}
+		// Sort keys can only be used in key conditions and other attributes only in filters.
+		if sortKey {
+			queryInput.KeyConditions[versionAttr] = condition
+		} else {
+			queryInput.QueryFilter = map[string]*dynamodb.Condition{
+				versionAttr: condition,
+			}
+		}
+	}
+
+	if f := activeFilter(); f != "" {
+		conditions, err := parseFilter(f)
+		if err != nil {
+			return "", err
+		}
+		if queryInput.QueryFilter == nil {
+			queryInput.QueryFilter = make(map[string]*dynamodb.Condition)
+		}
+		for attr, condition := range conditions {
+			queryInput.QueryFilter[attr] = condition
+		}
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	err := queryItems(queryInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		if !expired(table, attrs) {
+			items = append(items, attrs)
+		}
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if *resp.Count != 1 {
-		return "", fmt.Errorf("error querying for \"%v\": %v occurrences found", key, *resp.Count)
+	name := key
+	if version != "" {
+		name = fmt.Sprintf("%s@%s", key, version)
+	}
+	if len(items) == 0 {
+		return "", fmt.Errorf("error querying for \"%v\": %w", name, errNotFound)
+	}
+	if len(items) != 1 {
+		return "", fmt.Errorf("error querying for \"%v\": %v occurrences found (narrow them down with -filter or %s)", name, len(items), modFilter)
 	}
-	s := resp.Items[0]["Value"].S
+	if err := checkItem(items[0]); err != nil {
+		return "", err
+	}
+	s := items[0]["Value"].S
 
 	return *s, nil
 }
 
+// Returns the items whose key matches the pattern, sorted by key.
+// Both the pattern and the keys returned are relative to the prefix.
+// Patterns follow the syntax of path.Match, so "app/hosts/*" matches "app/hosts/web1" but not "app/hosts/web1/port".
+func dynamodbScan(table, pattern string) ([]item, error) {
+	pattern = prefix + pattern
+
+	// Only items sharing the literal prefix of the pattern can match it.
+	literal := pattern
+	if i := strings.IndexAny(pattern, "*?[\\"); i >= 0 {
+		literal = pattern[:i]
+	}
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(table),
+		FilterExpression: aws.String("begins_with(#k, :prefix)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#k": aws.String("Key"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prefix": {
+				S: aws.String(literal),
+			},
+		},
+	}
+
+	var items []item
+	err := scanItems(scanInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		key := aws.StringValue(attrs["Key"].S)
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return err
+		}
+		if matched && attrs["Value"] != nil && !expired(table, attrs) {
+			if err := checkItem(attrs); err != nil {
+				return err
+			}
+			items = append(items, item{Key: strings.TrimPrefix(key, prefix), Value: aws.StringValue(attrs["Value"].S)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+
+	return items, nil
+}
+
+// Reports whether the attribute is the sort key of the table.
+func isSortKey(table, attr string) (bool, error) {
+	desc, err := describeTable(table)
+	if err != nil {
+		return false, err
+	}
+
+	for _, element := range desc.KeySchema {
+		if aws.StringValue(element.KeyType) == dynamodb.KeyTypeRange && aws.StringValue(element.AttributeName) == attr {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Returns the description of the table.
+// Descriptions are cached for the duration of the run.
+func describeTable(table string) (*dynamodb.TableDescription, error) {
+	if desc, ok := tableDescriptions[cacheKey(table)]; ok {
+		return desc, nil
+	}
+
+	svc := dynamodb.New(sess, regionConfig())
+	resp, err := svc.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(table),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tableDescriptions[cacheKey(table)] = resp.Table
+
+	return resp.Table, nil
+}
+
+// Returns every key in the table.
+// Listings are cached for the duration of the run, as they require scanning the whole table.
+func listKeys(table string) ([]string, error) {
+	if keys, ok := keyListings[cacheKey(table)]; ok {
+		return keys, nil
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:            aws.String(table),
+		ProjectionExpression: aws.String("#k"),
+		ExpressionAttributeNames: map[string]*string{
+			"#k": aws.String("Key"),
+		},
+	}
+
+	var keys []string
+	err := scanItems(scanInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		keys = append(keys, aws.StringValue(attrs["Key"].S))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	keyListings[cacheKey(table)] = keys
+
+	return keys, nil
+}
+
 func kmsDecrypt(value string) (string, error) {
+	stats.decrypted++
 	decoded, err := base64.StdEncoding.DecodeString(value)
 	if err != nil {
 		return "", err
@@ -208,7 +1363,7 @@ func kmsDecrypt(value string) (string, error) {
 		CiphertextBlob: decoded,
 	}
 
-	svc := kms.New(sess)
+	svc := kms.New(sess, regionConfig())
 	res, err := svc.Decrypt(decryptInput)
 	if err != nil {
 		return "", err
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+// Sets the values of the keys of the table used by placeholders without one,
+// serving them from the prefetched snapshot so that no AWS request is made.
+func setValues(t *testing.T, values map[string]string) {
+	t.Helper()
+	savedTable, savedSnapshot := table, snapshot
+	t.Cleanup(func() { table, snapshot = savedTable, savedSnapshot })
+
+	table = "test-settings"
+	snapshot = map[string]map[string]*string{table: {}}
+	for k, v := range values {
+		v := v
+		snapshot[table][k] = &v
+	}
+}
+
+// Sets the flag for the duration of the test.
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	saved := flag.Lookup(name).Value.String()
+	t.Cleanup(func() { flag.Set(name, saved) })
+
+	if err := flag.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A template along with its expected rendering.
+type renderTest struct {
+	name, input, want string
+}
+
+// Checks the rendering of every template with the function of a format.
+func testRender(t *testing.T, renderFormat func(string) (string, error), tests []renderTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderFormat(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
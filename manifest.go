@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+var (
+	// File supplied with -manifest, where the manifest of the render is written.
+	manifestFile string
+	// AWS KMS key supplied with -manifest-key, signing the manifest.
+	manifestKey string
+	// Keys looked up during the render, as "table:key".
+	usedKeys = map[string]bool{}
+)
+
+// Describes how a file was rendered, so that downstream systems can verify its provenance.
+type manifest struct {
+	File      string    `json:"file"`
+	SHA256    string    `json:"sha256"`
+	Table     string    `json:"table"`
+	Keys      []string  `json:"keys"`
+	Caller    string    `json:"caller"`
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	// Base64-encoded signature of the SHA-256 digest of the manifest without it, when signed with -manifest-key.
+	Signature []byte `json:"signature,omitempty"`
+	// Key and algorithm of the signature, as needed to verify it with kms:Verify.
+	KeyID            string `json:"keyId,omitempty"`
+	SigningAlgorithm string `json:"signingAlgorithm,omitempty"`
+}
+
+// Writes the manifest of the output rendered for the file to the file supplied with -manifest, if any.
+func writeManifest(file, output string) error {
+	if manifestFile == "" {
+		return nil
+	}
+
+	caller, _, err := metadata("@caller-arn")
+	if err != nil {
+		return err
+	}
+	m := manifest{
+		File:      file,
+		SHA256:    fmt.Sprintf("%x", sha256.Sum256([]byte(output))),
+		Table:     table,
+		Keys:      []string{},
+		Caller:    caller,
+		Version:   version,
+		Timestamp: started,
+	}
+	for key := range usedKeys {
+		m.Keys = append(m.Keys, key)
+	}
+	sort.Strings(m.Keys)
+
+	if manifestKey != "" {
+		unsigned, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(unsigned)
+		svc := kms.New(sess, regionConfig())
+		resp, err := svc.Sign(&kms.SignInput{
+			KeyId:            aws.String(manifestKey),
+			Message:          digest[:],
+			MessageType:      aws.String(kms.MessageTypeDigest),
+			SigningAlgorithm: aws.String(signingAlgorithm),
+		})
+		if err != nil {
+			return fmt.Errorf("error signing manifest: %w", err)
+		}
+		m.Signature = resp.Signature
+		m.KeyID = aws.StringValue(resp.KeyId)
+		m.SigningAlgorithm = aws.StringValue(resp.SigningAlgorithm)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFile(manifestFile, append(data, '\n'), 0644)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Version of dynsubst, set at build time with:
+// go build -ldflags "-X main.version=1.0.0"
+var version = "dev"
+
+var (
+	// Time at which the run started, shared by every timestamp placeholder.
+	started = time.Now().UTC()
+	// ARN of the identity used to call AWS, once retrieved.
+	callerARN string
+)
+
+// Returns the value of a metadata placeholder, such as "{{@timestamp}}", and whether the key refers to one.
+// These are meant for stamping rendered files with their provenance.
+func metadata(key string) (string, bool, error) {
+	switch key {
+	case "@timestamp":
+		return started.Format(time.RFC3339), true, nil
+	case "@table":
+		return table, true, nil
+	case "@dynsubst-version":
+		return version, true, nil
+	case "@caller-arn":
+		if callerARN == "" {
+			svc := sts.New(sess)
+			resp, err := svc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+			if err != nil {
+				return "", true, err
+			}
+			callerARN = aws.StringValue(resp.Arn)
+		}
+		return callerARN, true, nil
+	}
+
+	return "", false, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Returns the MFA token code supplied with -mfa-token or prompts for it.
+// The prompt is read from the terminal rather than from standard input, which may contain the template.
+func mfaTokenProvider() (string, error) {
+	if mfaToken != "" {
+		return mfaToken, nil
+	}
+
+	input := os.Stdin
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		input = tty
+	}
+	fmt.Fprintf(os.Stderr, "MFA token code for profile \"%s\": ", profile)
+	code, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("error reading MFA token code: %w", err)
+	}
+
+	return strings.TrimSpace(code), nil
+}
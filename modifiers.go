@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Encode value in base64.
+	// This is mostly useful for Kubernetes Secret manifests: "{{B64:DECRYPT:Password}}".
+	modB64 = "B64"
+	// Alias of B64, as in "{{BASE64:TRIM:DECRYPT:Password}}".
+	modBase64 = "BASE64"
+	// Escape value so that it can be embedded in a JSON string literal.
+	// Ex.: {"password": "{{JSONESCAPE:DECRYPT:Password}}"}
+	modJSONEscape = "JSONESCAPE"
+	// Indent every line of the value but the first by the amount of spaces specified.
+	// The first line is expected to be indented by the placeholder itself, as in YAML block scalars:
+	//   key: |
+	//     {{INDENT=4:DECRYPT:TlsKey}}
+	modIndent = "INDENT"
+	// Percent-encode every character of the value but unreserved ones (RFC 3986).
+	// Ex.: "postgres://{{DBUser}}:{{URLENCODE:DECRYPT:DBPass}}@{{DBHost}}"
+	modURLEncode = "URLENCODE"
+	// Quote value for POSIX shells, so that it is always interpreted as a single literal word.
+	// Ex.: "export PASSWORD={{SHELLQUOTE:DECRYPT:Password}}"
+	modShellQuote = "SHELLQUOTE"
+	// Convert value to uppercase.
+	modUpper = "UPPER"
+	// Convert value to lowercase.
+	modLower = "LOWER"
+	// Remove leading and trailing whitespace from value.
+	modTrim = "TRIM"
+	// Replace value by its hexadecimal SHA-256 digest.
+	// This allows deriving fingerprints from secrets without exposing them: "{{SHA256:DECRYPT:Password}}".
+	modSHA256 = "SHA256"
+	// Replace value by its hexadecimal MD5 digest.
+	modMD5 = "MD5"
+	// Replace value by its hexadecimal CRC-32 (IEEE) checksum.
+	modCRC32 = "CRC32"
+	// Encode value in hexadecimal, as required by some formats for binary data and key material.
+	modHex = "HEX"
+)
+
+// Modifiers transforming values, indexed by name.
+// Each receives the value and the argument supplied with "NAME=argument", if any.
+var modifiers = map[string]func(value, arg string) (string, error){
+	modDecrypt: func(value, arg string) (string, error) {
+		return decrypt(value)
+	},
+	modB64:        encodeBase64,
+	modBase64:     encodeBase64,
+	modJSONEscape: jsonEscape,
+	modIndent:     indent,
+	modURLEncode:  urlEncode,
+	modShellQuote: shellQuote,
+	modUpper: func(value, arg string) (string, error) {
+		return strings.ToUpper(value), nil
+	},
+	modLower: func(value, arg string) (string, error) {
+		return strings.ToLower(value), nil
+	},
+	modTrim: func(value, arg string) (string, error) {
+		return strings.TrimSpace(value), nil
+	},
+	modSHA256: func(value, arg string) (string, error) {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(value))), nil
+	},
+	modMD5: func(value, arg string) (string, error) {
+		return fmt.Sprintf("%x", md5.Sum([]byte(value))), nil
+	},
+	modCRC32: func(value, arg string) (string, error) {
+		return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(value))), nil
+	},
+	modHex: func(value, arg string) (string, error) {
+		return hex.EncodeToString([]byte(value)), nil
+	},
+}
+
+// Returns the value encoded in base64.
+func encodeBase64(value, arg string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(value)), nil
+}
+
+// Returns the value escaped for a JSON string literal, without the surrounding quotes.
+func jsonEscape(value, arg string) (string, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+
+	// Strip the surrounding quotes and the newline added by the encoder.
+	escaped := strings.TrimSuffix(b.String(), "\n")
+	return escaped[1 : len(escaped)-1], nil
+}
+
+// Returns the value with every line but the first indented by the amount of spaces in arg.
+func indent(value, arg string) (string, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid amount of spaces: \"%s\"", arg)
+	}
+
+	lines := strings.Split(value, "\n")
+	for i := 1; i < len(lines); i++ {
+		// Empty lines are left as is to avoid trailing whitespace.
+		if lines[i] != "" {
+			lines[i] = strings.Repeat(" ", n) + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Returns the value with every byte but unreserved characters percent-encoded.
+// Unlike url.QueryEscape, this is safe to use in any part of a URL, including user information.
+func urlEncode(value, arg string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// Returns the value in single quotes, closing and reopening them around each escaped single quote.
+func shellQuote(value, arg string) (string, error) {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'", nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// Shell command run after writing files whose contents changed, supplied with -exec-on-change.
+var execOnChange string
+
+// Runs the shell command, such as one reloading the service reading the files written,
+// with its output going to standard error so that it is not mixed with rendered templates.
+func runOnChange(command string) error {
+	if verbose || veryVerbose {
+		log.Printf("running \"%s\"", command)
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running \"%s\": %w", command, err)
+	}
+
+	return nil
+}
+
+// Runs the command supplied with -exec-on-change if any file changed, exiting on failure.
+func runOnChangeIf(changed bool) {
+	if !changed || execOnChange == "" {
+		return
+	}
+	if err := runOnChange(execOnChange); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Calls the function with every item returned by the scan until it returns an error,
+// following LastEvaluatedKey so that results are not truncated at 1 MB per page.
+func scanItems(input *dynamodb.ScanInput, f func(attrs map[string]*dynamodb.AttributeValue) error) error {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+
+	var fErr error
+	err := dynamodb.New(sess, regionConfig()).ScanPages(input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		countCapacity(page.ConsumedCapacity)
+		for _, attrs := range page.Items {
+			if fErr = f(attrs); fErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return fErr
+}
+
+// Calls the function with every item returned by the query until it returns an error,
+// following LastEvaluatedKey so that results are not truncated at 1 MB per page,
+// which matters for queries with filters as pages may be empty before the last one.
+func queryItems(input *dynamodb.QueryInput, f func(attrs map[string]*dynamodb.AttributeValue) error) error {
+	input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+
+	var fErr error
+	err := dynamodb.New(sess, regionConfig()).QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		countCapacity(page.ConsumedCapacity)
+		for _, attrs := range page.Items {
+			if fErr = f(attrs); fErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	return fErr
+}
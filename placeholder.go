@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Matches a modifier, optionally with an argument, at the beginning of a placeholder.
+var modifierRe = regexp.MustCompile(`^(\w+)(?:=([^:]*))?:`)
+
+// A placeholder split into its parts.
+type placeholder struct {
+	// Placeholder to output instead of a value, when it contains the SKIP modifier.
+	skipped string
+	// Modifier providing the value instead of the table, such as INCLUDE or JOIN, and its argument.
+	source, sourceArg string
+	// Table where the key is looked up.
+	table string
+	// Alias of the account where the table is, if not the default one.
+	account string
+	// Region where the table is, if not the default one.
+	region string
+	// Filter narrowing down the items of the key, if any.
+	filter string
+	// Modifiers transforming the value, outermost first.
+	modifiers []modifier
+	key       string
+}
+
+// A modifier transforming the value of a placeholder.
+type modifier struct {
+	name, arg string
+}
+
+// Splits a placeholder into its parts, consuming modifiers from left to right until reaching the key.
+func parsePlaceholder(input string) (*placeholder, error) {
+	p := &placeholder{table: table}
+	rest := strings.TrimSuffix(strings.TrimPrefix(input, "{{"), "}}")
+	for {
+		m := modifierRe.FindStringSubmatch(rest)
+		if m == nil {
+			break
+		}
+		name, arg := m[1], m[2]
+
+		switch {
+		case name == modGet:
+			p.key = rest[len(m[0]):]
+			return p, nil
+		case name == modSkip:
+			p.skipped = fmt.Sprintf("{{%s%s}}", strings.TrimSuffix(input[2:], rest+"}}"), rest[len(m[0]):])
+			return p, nil
+		case name == modInclude || name == modJoin:
+			p.source = name
+			p.key = rest[len(m[0]):]
+			return p, nil
+		case name == modPlugin:
+			p.source, p.sourceArg = name, arg
+			p.key = rest[len(m[0]):]
+			return p, nil
+		case name == modTable:
+			p.table = arg
+		case name == modRegion:
+			p.region = arg
+		case name == modFilter:
+			p.filter = arg
+		case modifiers[name] != nil:
+			p.modifiers = append(p.modifiers, modifier{name, arg})
+		case tables[name] != "" && m[0] == name+":":
+			p.table = tables[name]
+		case accounts[name] != "" && m[0] == name+":":
+			p.account = name
+		case isUpper(name):
+			return nil, fmt.Errorf("unknown modifier %s in \"%s\"", name, input)
+		default:
+			p.key = rest
+			return p, nil
+		}
+		rest = rest[len(m[0]):]
+	}
+	p.key = rest
+
+	return p, nil
+}
+
+// Reports whether the name is entirely in uppercase, as modifiers are.
+func isUpper(name string) bool {
+	for _, r := range name {
+		if unicode.IsLower(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlaceholder(t *testing.T) {
+	setValues(t, nil)
+
+	tests := []struct {
+		input string
+		want  placeholder
+	}{
+		{"{{Key}}", placeholder{table: "test-settings", key: "Key"}},
+		{"{{DECRYPT:Key}}", placeholder{table: "test-settings", key: "Key", modifiers: []modifier{{modDecrypt, ""}}}},
+		{"{{BASE64:TRIM:DECRYPT:Key}}", placeholder{table: "test-settings", key: "Key", modifiers: []modifier{{modBase64, ""}, {modTrim, ""}, {modDecrypt, ""}}}},
+		{"{{INDENT=4:Key}}", placeholder{table: "test-settings", key: "Key", modifiers: []modifier{{modIndent, "4"}}}},
+		{"{{TABLE=credentials:Key}}", placeholder{table: "credentials", key: "Key"}},
+		{"{{GET:DECRYPT:Key}}", placeholder{table: "test-settings", key: "DECRYPT:Key"}},
+		{"{{SKIP:UPPER:Key}}", placeholder{table: "test-settings", skipped: "{{UPPER:Key}}"}},
+		{"{{app:Key}}", placeholder{table: "test-settings", key: "app:Key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parsePlaceholder(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlaceholderUnknownModifier(t *testing.T) {
+	if _, err := parsePlaceholder("{{NOPE:Key}}"); err == nil {
+		t.Error("expected an error for an unknown modifier")
+	}
+}
+
+func TestResolvePlaceholderChain(t *testing.T) {
+	setValues(t, map[string]string{"Key": " secret \n"})
+
+	tests := []struct {
+		input, want string
+	}{
+		{"{{TRIM:Key}}", "secret"},
+		{"{{UPPER:TRIM:Key}}", "SECRET"},
+		{"{{BASE64:TRIM:Key}}", "c2VjcmV0"},
+		{"{{B64:TRIM:Key}}", "c2VjcmV0"},
+		{"{{TRIM:BASE64:Key}}", "IHNlY3JldCAK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := resolvePlaceholder(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
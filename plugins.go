@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Retrieve value from a plugin instead of AWS DynamoDB.
+	// Ex.: "{{PLUGIN=vault:secret/db/password}}"
+	modPlugin = "PLUGIN"
+
+	// Actions requested from plugins.
+	pluginResolve = "resolve"
+	pluginModify  = "modify"
+
+	// Extension of WebAssembly plugins.
+	wasmExt = ".wasm"
+)
+
+// Plugins are executables in the plugin directory, named after the modifier they implement in lowercase.
+// For each invocation, dynsubst writes a JSON request to the standard input of the plugin:
+//
+//	{"action": "modify", "value": "...", "arg": "..."}   Transform the value, as in "{{NAME=arg:Key}}".
+//	{"action": "resolve", "key": "..."}                  Retrieve the value of the key, as in "{{PLUGIN=name:Key}}".
+//
+// The plugin must write a JSON response to its standard output, either {"value": "..."} or {"error": "..."}.
+type pluginRequest struct {
+	Action string `json:"action"`
+	Key    string `json:"key,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Arg    string `json:"arg,omitempty"`
+}
+
+type pluginResponse struct {
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+var (
+	// Paths of the plugins loaded, indexed by name.
+	plugins = map[string]string{}
+
+	// Matches names of plugins that can be used as modifiers.
+	pluginNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// Returns the default plugin directory.
+func defaultPluginDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".dynsubst", "plugins")
+}
+
+// Registers every executable and WebAssembly module in the directory as a modifier.
+// Plugins cannot override built-in modifiers.
+func loadPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), wasmExt)
+		wasm := name != entry.Name()
+		if entry.IsDir() || !pluginNameRe.MatchString(name) || !wasm && entry.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		mod := strings.ToUpper(name)
+		if _, ok := modifiers[mod]; ok {
+			log.Printf("warning: ignoring plugin \"%s\" as it conflicts with the %s modifier", entry.Name(), mod)
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if wasm {
+			modifiers[mod] = wasmModifier(path)
+			continue
+		}
+		plugins[name] = path
+		modifiers[mod] = func(value, arg string) (string, error) {
+			return callPlugin(path, pluginRequest{Action: pluginModify, Value: value, Arg: arg})
+		}
+	}
+
+	return nil
+}
+
+// Returns the value of the key retrieved from the plugin.
+func pluginValue(name, key string) (string, error) {
+	path, ok := plugins[name]
+	if !ok {
+		return "", fmt.Errorf("unknown plugin \"%s\"", name)
+	}
+
+	return callPlugin(path, pluginRequest{Action: pluginResolve, Key: key})
+}
+
+// Runs the plugin with the request and returns the value in its response.
+func callPlugin(path string, request pluginRequest) (string, error) {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("plugin %s: %w", filepath.Base(path), err)
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return "", fmt.Errorf("plugin %s: invalid response: %w", filepath.Base(path), err)
+	}
+	if response.Error != "" {
+		return "", fmt.Errorf("plugin %s: %s", filepath.Base(path), response.Error)
+	}
+
+	return response.Value, nil
+}
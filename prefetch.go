@@ -0,0 +1,190 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Maximum amount of keys that can be retrieved in a single TransactGetItems request.
+const maxTransactItems = 100
+
+var (
+	// Values retrieved by prefetching, indexed by table and key.
+	// Keys which were prefetched but do not exist are stored with a nil value.
+	snapshot = map[string]map[string]*string{}
+	// Amount of keys retrieved in each transaction, which is only lowered by the bench subcommand.
+	transactSize = maxTransactItems
+)
+
+// Fetches every key referenced in the text before rendering, in transactions of up to maxTransactItems keys,
+// so that the values of the keys of each transaction are consistent with each other even when the table
+// is updated concurrently. Renders with more keys than that are only consistent per transaction.
+// Tables with more referenced keys than -scan-threshold are scanned instead, which is cheaper for small tables
+// but only strongly consistent per item, as scans are not a point-in-time snapshot of the table,
+// and only these are fetched ahead of rendering when running without -prefetch.
+// Keys which can only be known while rendering, such as those in values resolved recursively,
+// in included templates, pinned to a version or matched case-insensitively, are fetched as usual.
+func prefetch(text string) error {
+	keys := make(map[string]map[string]bool)
+	for _, input := range placeholderRe.FindAllString(text, -1) {
+		t, key, ok := prefetchable(input)
+		if !ok {
+			continue
+		}
+		if keys[t] == nil {
+			keys[t] = make(map[string]bool)
+		}
+		for _, k := range candidates(key) {
+			keys[t][k] = true
+		}
+	}
+
+	for t, set := range keys {
+		// Items can only be retrieved in transactions by their whole primary key.
+		desc, err := describeTable(t)
+		if err != nil {
+			return err
+		}
+		if len(desc.KeySchema) > 1 {
+			log.Printf("warning: not prefetching keys from \"%s\" as it has a sort key", t)
+			continue
+		}
+
+		var batch []string
+		for k := range set {
+			batch = append(batch, k)
+		}
+		switch {
+		case scanThreshold > 0 && len(batch) > scanThreshold:
+			err = scanAll(t, batch)
+		case prefetchMode:
+			err = transactGet(t, batch)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Retrieves every item of the table with a strongly consistent scan and stores them in the snapshot,
+// along with the keys which are referenced but do not exist.
+// Items are read at different points in time, so concurrent updates may be partially visible.
+func scanAll(table string, keys []string) error {
+	values := snapshot[table]
+	if values == nil {
+		values = make(map[string]*string)
+		snapshot[table] = values
+	}
+	for _, k := range keys {
+		values[k] = nil
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:      aws.String(table),
+		ConsistentRead: aws.Bool(true),
+	}
+	return scanItems(scanInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		if attrs["Key"] != nil && attrs["Value"] != nil && !expired(table, attrs) {
+			if err := verifyChecksum(attrs); err != nil {
+				return err
+			}
+			values[aws.StringValue(attrs["Key"].S)] = attrs["Value"].S
+		}
+		return nil
+	})
+}
+
+// Returns the table and the key referenced by a placeholder or a block, if it can be prefetched.
+func prefetchable(input string) (string, string, bool) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(input, "{{"), "}}")
+	if strings.HasPrefix(inner, "#") {
+		fields := strings.Fields(inner)
+		if len(fields) < 2 || (fields[0] != "#"+blockIf && fields[0] != "#"+blockIfEq) {
+			return "", "", false
+		}
+		input = "{{" + fields[1] + "}}"
+	} else if strings.HasPrefix(inner, "/") {
+		return "", "", false
+	}
+
+	p, err := parsePlaceholder(input)
+	if err != nil || p.skipped != "" || p.source != "" || p.account != "" || p.region != "" || p.filter != "" || filter != "" || verifyKey != "" {
+		return "", "", false
+	}
+	if strings.HasPrefix(p.key, "@") || strings.HasPrefix(p.key, ".") || ignoreCase || !selected(p.key) {
+		return "", "", false
+	}
+	if _, version := splitVersion(p.key); version != "" {
+		return "", "", false
+	}
+
+	return p.table, p.key, p.table != ""
+}
+
+// Retrieves the keys from the table in transactions and stores them in the snapshot.
+// The values of every key retrieved in the same transaction are read at the same point in time,
+// which is only the case for all of the keys when there are no more than maxTransactItems of them.
+func transactGet(table string, keys []string) error {
+	svc := dynamodb.New(sess, regionConfig())
+
+	values := snapshot[table]
+	if values == nil {
+		values = make(map[string]*string)
+		snapshot[table] = values
+	}
+	for _, k := range keys {
+		values[k] = nil
+	}
+
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > transactSize {
+			n = transactSize
+		}
+		var items []*dynamodb.TransactGetItem
+		for _, k := range keys[:n] {
+			items = append(items, &dynamodb.TransactGetItem{
+				Get: &dynamodb.Get{
+					TableName: aws.String(table),
+					Key: map[string]*dynamodb.AttributeValue{
+						"Key": {
+							S: aws.String(k),
+						},
+					},
+				},
+			})
+		}
+		keys = keys[n:]
+
+		resp, err := svc.TransactGetItems(&dynamodb.TransactGetItemsInput{
+			TransactItems:          items,
+			ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		})
+		if err != nil {
+			return err
+		}
+		countCapacity(resp.ConsumedCapacity...)
+		for _, r := range resp.Responses {
+			attrs := r.Item
+			if attrs["Key"] != nil && attrs["Value"] != nil && !expired(table, attrs) {
+				if err := verifyChecksum(attrs); err != nil {
+					return err
+				}
+				values[aws.StringValue(attrs["Key"].S)] = attrs["Value"].S
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the value of a key from the snapshot and whether the key was prefetched.
+func snapshotValue(table, key string) (*string, bool) {
+	value, ok := snapshot[cacheKey(table)][key]
+	return value, ok
+}
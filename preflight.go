@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+const (
+	// Error code returned by AWS when the caller lacks a permission.
+	errCodeAccessDenied = "AccessDeniedException"
+	// Key looked up to probe permissions, which is not expected to exist.
+	preflightKey = "dynsubst-preflight"
+)
+
+// Checks that the caller has every permission required to render the template read from the file,
+// so that renders fail before looking up any value rather than halfway through.
+// Permissions are probed with requests which do not return values, or with dry runs.
+func preflight(file, text string) error {
+	access := newRequiredAccess()
+	if err := access.analyzeText(file, text); err != nil {
+		return err
+	}
+
+	var missing []string
+	check := func(action, resource string, err error) error {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == errCodeAccessDenied {
+			missing = append(missing, fmt.Sprintf("%s on %s", action, resource))
+			return nil
+		}
+		return err
+	}
+
+	svc := dynamodb.New(sess, regionConfig())
+	for t, actions := range access.tables {
+		for action := range actions {
+			var err error
+			switch action {
+			case "dynamodb:Query":
+				_, err = svc.Query(&dynamodb.QueryInput{
+					TableName: aws.String(t),
+					KeyConditions: map[string]*dynamodb.Condition{
+						"Key": {
+							ComparisonOperator: aws.String("EQ"),
+							AttributeValueList: []*dynamodb.AttributeValue{{S: aws.String(preflightKey)}},
+						},
+					},
+				})
+			case "dynamodb:Scan":
+				_, err = svc.Scan(&dynamodb.ScanInput{TableName: aws.String(t), Limit: aws.Int64(1)})
+			case "dynamodb:GetItem":
+				_, err = svc.GetItem(&dynamodb.GetItemInput{
+					TableName: aws.String(t),
+					Key:       map[string]*dynamodb.AttributeValue{"Key": {S: aws.String(preflightKey)}},
+				})
+			case "dynamodb:DescribeTable":
+				_, err = svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(t)})
+			case "dynamodb:DescribeTimeToLive":
+				_, err = svc.DescribeTimeToLive(&dynamodb.DescribeTimeToLiveInput{TableName: aws.String(t)})
+			}
+			if err := check(action, t, err); err != nil {
+				return fmt.Errorf("preflight: %s on %s: %w", action, t, err)
+			}
+		}
+	}
+
+	// Decryption can only be probed with an actual ciphertext, as permissions depend on its key.
+	if p := access.decrypted; p != nil && len(missing) == 0 {
+		value, err := fetch(p.table, p.key)
+		if err != nil {
+			return fmt.Errorf("preflight: %w", err)
+		}
+		if err := kmsDryRunDecrypt(value); check("kms:Decrypt", "the key of "+p.key, err) != nil {
+			return fmt.Errorf("preflight: kms:Decrypt: %w", err)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("preflight: missing permissions: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// Checks that the value can be decrypted without decrypting it.
+// AWS KMS reports successful dry runs as DryRunOperationException errors.
+func kmsDryRunDecrypt(value string) error {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+
+	svc := kms.New(sess, regionConfig())
+	_, err = svc.Decrypt(&kms.DecryptInput{
+		CiphertextBlob: decoded,
+		DryRun:         aws.Bool(true),
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == kms.ErrCodeDryRunOperationException {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// Minimum amount of placeholders for which progress is shown.
+	minProgress = 100
+	// Width of the progress bar, in characters.
+	progressWidth = 30
+	// Minimum interval between redraws of the progress bar.
+	progressInterval = 100 * time.Millisecond
+)
+
+// Progress of the render, shown on standard error when it is a terminal.
+var progress struct {
+	enabled      bool
+	total, done  int
+	start, drawn time.Time
+}
+
+// Starts showing progress if the text contains enough placeholders and standard error is a terminal
+// on which it does not interfere with logs.
+func startProgress(text string) {
+	total := len(placeholderRe.FindAllString(text, -1))
+	if total < minProgress || verbose || veryVerbose || !isTerminal(os.Stderr) {
+		return
+	}
+	progress.enabled, progress.total, progress.done = true, total, 0
+	progress.start = time.Now()
+}
+
+// Counts a resolved placeholder and redraws the progress bar if enough time has passed.
+func stepProgress() {
+	if !progress.enabled {
+		return
+	}
+	// Placeholders resolved recursively or in included templates are not known in advance.
+	if progress.done < progress.total {
+		progress.done++
+	}
+	if time.Since(progress.drawn) < progressInterval {
+		return
+	}
+	progress.drawn = time.Now()
+
+	filled := progressWidth * progress.done / progress.total
+	elapsed := time.Since(progress.start)
+	eta := time.Duration(float64(elapsed) * float64(progress.total-progress.done) / float64(progress.done))
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %d/%d ETA %v\033[K", strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled),
+		progress.done, progress.total, eta.Round(time.Second))
+}
+
+// Clears the progress bar.
+func endProgress() {
+	if !progress.enabled {
+		return
+	}
+	progress.enabled = false
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
+
+// Reports whether the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
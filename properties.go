@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Returns the Java properties file after replacing placeholders in values, escaping them
+// so that they are read back as is. Placeholders in comments and keys are left as is.
+func renderProperties(text string) (string, error) {
+	lines := strings.Split(text, "\n")
+	var continued bool // Whether the line continues the value of the previous one.
+	for n, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t\f")
+		wasContinued := continued
+		continued = propertiesContinues(line)
+		if !wasContinued && (trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!') {
+			// Comments cannot be continued.
+			continued = false
+			continue
+		}
+		if !strings.Contains(line, "{{") {
+			continue
+		}
+
+		start := len(line) - len(trimmed)
+		if !wasContinued {
+			start = propertiesValueStart(line, start)
+		}
+		value, err := propertiesValue(line[start:])
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", n+1, err)
+		}
+		lines[n] = line[:start] + value
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Reports whether the line ends with an odd amount of backslashes, continuing on the next line.
+func propertiesContinues(line string) bool {
+	line = strings.TrimSuffix(line, "\r")
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// Returns the offset of the value of the entry in the line, whose key starts at the offset supplied.
+// Keys end at the first unescaped "=", ":" or whitespace, optionally followed by whitespace and a separator.
+func propertiesValueStart(line string, i int) int {
+	for i < len(line) && !strings.ContainsRune("=: \t\f", rune(line[i])) {
+		if line[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	for i < len(line) && strings.ContainsRune(" \t\f", rune(line[i])) {
+		i++
+	}
+	if i < len(line) && (line[i] == '=' || line[i] == ':') {
+		i++
+	}
+	for i < len(line) && strings.ContainsRune(" \t\f", rune(line[i])) {
+		i++
+	}
+	if i > len(line) {
+		i = len(line)
+	}
+
+	return i
+}
+
+// Returns the value after replacing its placeholders with their escaped values.
+func propertiesValue(value string) (string, error) {
+	var b strings.Builder
+	for {
+		loc := placeholderRe.FindStringIndex(value)
+		if loc == nil {
+			break
+		}
+		b.WriteString(value[:loc[0]])
+		v, err := resolve(value[loc[0]:loc[1]])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(propertiesEscape(v, b.Len() == 0))
+		value = value[loc[1]:]
+	}
+	b.WriteString(value)
+
+	return b.String(), nil
+}
+
+// Returns the value escaped as in Java properties files, with characters outside of ASCII
+// written as Unicode escapes, as Properties.store does, so that it is read correctly in any encoding.
+// Leading whitespace is escaped when the value starts the entry, as it would be skipped otherwise.
+func propertiesEscape(value string, leading bool) string {
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case r == ' ' && leading && strings.TrimLeft(value[:i], " ") == "":
+			b.WriteString(`\ `)
+		case r == '\\' || r == '=' || r == ':' || r == '#' || r == '!':
+			b.WriteString(`\` + string(r))
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r < 0x20 || r > 0x7e:
+			if r > 0xffff {
+				r1, r2 := utf16.EncodeRune(r)
+				fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestRenderProperties(t *testing.T) {
+	setValues(t, map[string]string{
+		"Host":     "db.example.com",
+		"Padded":   "  x",
+		"Password": `a=b:c#d!e\f`,
+		"Greeting": "héllo\n\t😀",
+	})
+
+	testRender(t, renderProperties, []renderTest{
+		{"equals", "db.host={{Host}}", "db.host=db.example.com"},
+		{"colon and spaces", "db.host : {{Host}}", "db.host : db.example.com"},
+		{"whitespace separator", "db.host {{Host}}", "db.host db.example.com"},
+		{"special characters", "db.password={{Password}}", `db.password=a\=b\:c\#d\!e\\f`},
+		{"leading whitespace", "padded={{Padded}}", `padded=\ \ x`},
+		{"embedded whitespace", "padded=[{{Padded}}]", "padded=[  x]"},
+		{"unicode escapes", "greeting={{Greeting}}", `greeting=h\u00e9llo\n\t\ud83d\ude00`},
+		{"comments", "# {{Host}}\n! {{Host}}", "# {{Host}}\n! {{Host}}"},
+		{"keys", "{{Host}}=x", "{{Host}}=x"},
+		{"continuation", "hosts=a,\\\n  {{Host}}", "hosts=a,\\\n  db.example.com"},
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// Mirrors the items of the table into AWS SSM Parameter Store, named after their keys under a path prefix,
+// so that consumers of Parameter Store stay in sync with the table.
+// Values encrypted with AWS KMS are decrypted and stored as SecureString parameters, encrypted with
+// the key supplied with -key-id or the AWS managed one, while the rest are stored as String parameters.
+// Only keys selected with -only and -ignore are mirrored, and parameters already holding the value are not updated.
+// Ex.: dynsubst -only 'app/*' push-ssm app-settings -prefix /app/prod/
+func runPushSSM(args []string) {
+	fs := flag.NewFlagSet("push-ssm", flag.ExitOnError)
+	pathPrefix := fs.String("prefix", "/", "specify path prefix of the parameters")
+	keyID := fs.String("key-id", "", "specify AWS KMS key of SecureString parameters (default: the AWS managed key)")
+	if len(args) < 1 {
+		log.Fatal("push-ssm: usage: push-ssm table [-prefix prefix] [-key-id key]")
+	}
+	table = args[0]
+	fs.Parse(args[1:])
+	if !strings.HasPrefix(*pathPrefix, "/") {
+		log.Fatal("push-ssm: -prefix must start with \"/\"")
+	}
+
+	svc := ssm.New(sess)
+	current := map[string]string{}
+	err := svc.GetParametersByPathPages(&ssm.GetParametersByPathInput{
+		Path:           pathPrefix,
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}, func(page *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		for _, p := range page.Parameters {
+			current[aws.StringValue(p.Name)] = aws.StringValue(p.Value)
+		}
+		return true
+	})
+	if err != nil {
+		log.Fatalf("push-ssm: %v", err)
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(table),
+	}
+	var pushed, kept int
+	err = scanItems(scanInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		if attrs["Key"] == nil || attrs["Value"] == nil || expired(table, attrs) {
+			return nil
+		}
+		key := aws.StringValue(attrs["Key"].S)
+		if !strings.HasPrefix(key, prefix) || !selected(strings.TrimPrefix(key, prefix)) {
+			return nil
+		}
+		if err := checkItem(attrs); err != nil {
+			return err
+		}
+
+		value, paramType := aws.StringValue(attrs["Value"].S), ssm.ParameterTypeString
+		if decoded, err := base64.StdEncoding.DecodeString(value); err == nil && bytes.HasPrefix(decoded, kmsCiphertextHeader) {
+			value, err = kmsDecrypt(value)
+			if err != nil {
+				return err
+			}
+			paramType = ssm.ParameterTypeSecureString
+		}
+
+		name := strings.TrimSuffix(*pathPrefix, "/") + "/" + strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/")
+		if v, ok := current[name]; ok && v == value {
+			kept++
+			return nil
+		}
+		input := &ssm.PutParameterInput{
+			Name:      aws.String(name),
+			Value:     aws.String(value),
+			Type:      aws.String(paramType),
+			Overwrite: aws.Bool(true),
+		}
+		if paramType == ssm.ParameterTypeSecureString && *keyID != "" {
+			input.KeyId = keyID
+		}
+		if _, err := svc.PutParameter(input); err != nil {
+			return err
+		}
+		pushed++
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("push-ssm: %v", err)
+	}
+
+	fmt.Printf("updated %d parameters under \"%s\" (%d already up to date)\n", pushed, *pathPrefix, kept)
+}
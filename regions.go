@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Error code returned by the SDK when a request cannot be sent, as when the endpoint is unreachable.
+const errCodeRequestError = "RequestError"
+
+var (
+	// Regions supplied with -regions, in order of preference.
+	// Lookups are retried in the next one when failing with regional errors, as for replicas of global tables.
+	regionList []string
+	// Index of the region to fail over to next.
+	nextRegion int
+	// Region failed over to, if any, which AWS DynamoDB and AWS KMS clients use for the rest of the run.
+	failoverRegion string
+
+	// Sessions created for every region specified with the REGION modifier so far, indexed by account and region.
+	regionSessions = map[string]*session.Session{}
+	// Region specified by the placeholder being resolved, if any.
+	currentRegion string
+)
+
+// Parses the regions supplied with -regions, which take precedence over -r.
+func setRegions(list string) error {
+	if list == "" {
+		return nil
+	}
+
+	for _, r := range strings.Split(list, ",") {
+		if r = strings.TrimSpace(r); r == "" {
+			return fmt.Errorf("invalid list of regions \"%s\"", list)
+		}
+		regionList = append(regionList, r)
+	}
+	region, nextRegion = regionList[0], 1
+
+	return nil
+}
+
+// Calls the function, retrying it in the next region supplied with -regions while it fails with regional errors.
+// Once failed over, the next region is used for the rest of the run.
+func failover(f func() error) error {
+	for {
+		err := f()
+		if err == nil || !regionalError(err) || nextRegion >= len(regionList) {
+			return err
+		}
+
+		log.Printf("warning: %v: failing over to %s", err, regionList[nextRegion])
+		failoverRegion = regionList[nextRegion]
+		nextRegion++
+	}
+}
+
+// Returns the configuration of AWS DynamoDB and AWS KMS clients, which use the region failed over to, if any,
+// unless the placeholder being resolved specifies its region.
+// The region is not set in sessions, as they are restored when leaving the scope of accounts, regions and directives.
+func regionConfig() *aws.Config {
+	if failoverRegion == "" || currentRegion != "" {
+		return aws.NewConfig()
+	}
+	return aws.NewConfig().WithRegion(failoverRegion)
+}
+
+// Reports whether the error may be caused by an outage of the region rather than by the request.
+func regionalError(err error) bool {
+	var failure awserr.RequestFailure
+	if errors.As(err, &failure) {
+		return failure.StatusCode() >= 500
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == errCodeRequestError
+	}
+
+	return false
+}
+
+// Switches to a session for the region until the returned function is called.
+// The session uses the credentials of the current one, which may belong to another account.
+func useRegion(r string) func() {
+	key := currentAccount + ":" + r
+	s, ok := regionSessions[key]
+	if !ok {
+		s = sess.Copy(aws.NewConfig().WithRegion(r))
+		regionSessions[key] = s
+	}
+
+	savedSess, savedRegion := sess, currentRegion
+	sess, currentRegion = s, r
+	return func() {
+		sess, currentRegion = savedSess, savedRegion
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestFailoverOutlivesScopes(t *testing.T) {
+	savedSess, savedList, savedNext, savedFailover := sess, regionList, nextRegion, failoverRegion
+	t.Cleanup(func() { sess, regionList, nextRegion, failoverRegion = savedSess, savedList, savedNext, savedFailover })
+	s, err := session.NewSession(aws.NewConfig().WithRegion("us-east-1").WithCredentials(credentials.AnonymousCredentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, regionList, nextRegion, failoverRegion = s, []string{"us-east-1", "eu-west-1"}, 1, ""
+
+	// Fail over while the directives of a template apply, which restore the session once rendered.
+	restore := saveDirectives()
+	var attempts []string
+	err = failover(func() error {
+		attempts = append(attempts, aws.StringValue(regionConfig().Region))
+		if len(attempts) == 1 {
+			return awserr.New(errCodeRequestError, "endpoint unreachable", nil)
+		}
+		return nil
+	})
+	restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[0] != "" || attempts[1] != "eu-west-1" {
+		t.Errorf("got attempts in %q", attempts)
+	}
+
+	if got := aws.StringValue(regionConfig().Region); got != "eu-west-1" {
+		t.Errorf("got region %q after leaving the scope, want eu-west-1", got)
+	}
+	defer useRegion("ap-southeast-2")()
+	if got := regionConfig().Region; got != nil {
+		t.Errorf("got region %q for a placeholder specifying its region", *got)
+	}
+}
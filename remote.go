@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Pseudo-table under which templates retrieved from URLs are cached, along with their ETag.
+const cacheTemplatesTable = "@templates"
+
+var (
+	// Server-side encryption of objects written to AWS S3 with -output, supplied with -sse.
+	sseMode string
+	// AWS KMS key used for server-side encryption with -sse aws:kms, supplied with -sse-kms-key-id.
+	sseKMSKeyID string
+	// Whether the template being rendered was retrieved from a URL, in which case it cannot read local files.
+	remoteTemplate bool
+)
+
+// Reports whether the template is retrieved from a URL rather than read from a file.
+func isRemote(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "s3://")
+}
+
+// Returns the template at a "https://" or "s3://bucket/key" URL.
+// When running with -cache, templates are cached with their ETag and only downloaded again once changed.
+func readRemote(source string) ([]byte, error) {
+	cached, ok := cache[cacheTemplatesTable][source]
+	if cacheFile == "" || cached.ETag == "" {
+		ok = false
+	}
+
+	var body []byte
+	var etag string
+	var notModified bool
+	var err error
+	if strings.HasPrefix(source, "s3://") {
+		body, etag, notModified, err = readS3(source, ok, cached.ETag)
+	} else {
+		body, etag, notModified, err = readHTTPS(source, ok, cached.ETag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if notModified {
+		return []byte(cached.Value), nil
+	}
+
+	if cacheFile != "" && etag != "" {
+		if cache[cacheTemplatesTable] == nil {
+			cache[cacheTemplatesTable] = make(map[string]cacheEntry)
+		}
+		cache[cacheTemplatesTable][source] = cacheEntry{Value: string(body), Updated: time.Now().UTC(), ETag: etag}
+		cacheModified = true
+	}
+
+	return body, nil
+}
+
+// Returns the bucket and the key of a "s3://bucket/key" URL.
+func parseS3URL(u string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(u, "s3://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid S3 URL \"%s\": expected s3://bucket/key", u)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// Downloads the object at a "s3://bucket/key" URL, unless its ETag matches the cached one.
+func readS3(source string, conditional bool, cachedETag string) ([]byte, string, bool, error) {
+	bucket, key, err := parseS3URL(source)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if conditional {
+		input.IfNoneMatch = aws.String(cachedETag)
+	}
+	resp, err := s3.New(sess).GetObject(input)
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotModified {
+		return nil, "", true, nil
+	}
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	return body, aws.StringValue(resp.ETag), false, err
+}
+
+// Downloads the file at a "https://" URL, unless its ETag matches the cached one.
+func readHTTPS(source string, conditional bool, cachedETag string) ([]byte, string, bool, error) {
+	client, err := httpClient()
+	if err != nil {
+		return nil, "", false, err
+	}
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if conditional {
+		req.Header.Set("If-None-Match", cachedETag)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, "", true, nil
+	default:
+		return nil, "", false, fmt.Errorf("%s: %s", source, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+
+	return body, resp.Header.Get("ETag"), false, err
+}
+
+// Writes the output to the object at a "s3://bucket/key" URL, encrypted as specified with -sse.
+// The content type is detected from the extension of the key, or from the output itself.
+func writeS3(dst string, output []byte) error {
+	bucket, key, err := parseS3URL(dst)
+	if err != nil {
+		return err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = http.DetectContentType(output)
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(output),
+		ContentType: aws.String(contentType),
+	}
+	switch sseMode {
+	case "":
+		if sseKMSKeyID != "" {
+			return errors.New("-sse-kms-key-id requires -sse " + s3.ServerSideEncryptionAwsKms)
+		}
+	case s3.ServerSideEncryptionAwsKms:
+		input.ServerSideEncryption = aws.String(sseMode)
+		if sseKMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(sseKMSKeyID)
+		}
+	case s3.ServerSideEncryptionAes256:
+		input.ServerSideEncryption = aws.String(sseMode)
+	default:
+		return fmt.Errorf("invalid server-side encryption \"%s\": expected \"%s\" or \"%s\"", sseMode, s3.ServerSideEncryptionAwsKms, s3.ServerSideEncryptionAes256)
+	}
+
+	_, err = s3.New(sess).PutObject(input)
+
+	return err
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderSourceRemote(t *testing.T) {
+	setValues(t, map[string]string{"Host": "db.example.com"})
+	local := filepath.Join(t.TempDir(), "credentials")
+	if err := ioutil.WriteFile(local, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	templates := map[string]string{
+		"/plain.conf":    "host={{Host}}",
+		"/include.conf":  "{{INCLUDE:" + local + "}}",
+		"/relative.conf": "{{INCLUDE:credentials}}",
+		"/envsubst.conf": "#dynsubst: envsubst=true\nhome=$HOME",
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(templates[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(bundle, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, "ca-bundle", bundle)
+	saved := remoteTemplate
+	t.Cleanup(func() { remoteTemplate = saved })
+
+	rendered, err := renderSource(srv.URL+"/plain.conf", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.output != "host=db.example.com" {
+		t.Errorf("got %q", rendered.output)
+	}
+
+	for _, path := range []string{"/include.conf", "/relative.conf", "/envsubst.conf"} {
+		_, err := renderSource(srv.URL+path, true)
+		if err == nil || !strings.Contains(err.Error(), "remote templates") {
+			t.Errorf("%s: got error %v, want one rejecting it in remote templates", path, err)
+		}
+	}
+}
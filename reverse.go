@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Prints a template for an existing file, replacing the values of the table found in it with placeholders
+// for their keys, which eases onboarding files which were not rendered by dynsubst.
+// Longer values are replaced first, and values shorter than -min-length are ignored, as they often appear
+// in files by coincidence. The replacements made are logged so that they can be reviewed.
+// Ex.: dynsubst reverse app-settings /etc/nginx/nginx.conf > nginx.conf.tmpl
+func runReverse(args []string) {
+	fs := flag.NewFlagSet("reverse", flag.ExitOnError)
+	minLength := fs.Int("min-length", 4, "ignore values shorter than this")
+	if len(args) < 2 {
+		log.Fatal("reverse: usage: reverse table file [-min-length n]")
+	}
+	table = args[0]
+	file := args[1]
+	fs.Parse(args[2:])
+
+	input, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("reverse: %v", err)
+	}
+	text := string(input)
+
+	// Keys are sorted so that the same key is chosen when several share a value.
+	keys := map[string][]string{}
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(table),
+		FilterExpression: aws.String("begins_with(#k, :prefix)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#k": aws.String("Key"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prefix": {
+				S: aws.String(prefix),
+			},
+		},
+	}
+	err = scanItems(scanInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		if attrs["Key"] == nil || attrs["Value"] == nil || expired(table, attrs) {
+			return nil
+		}
+		value := aws.StringValue(attrs["Value"].S)
+		if len(value) < *minLength || !strings.Contains(text, value) {
+			return nil
+		}
+		keys[value] = append(keys[value], strings.TrimPrefix(aws.StringValue(attrs["Key"].S), prefix))
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("reverse: %v", err)
+	}
+
+	var values []string
+	for value := range keys {
+		sort.Strings(keys[value])
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	// Matches are replaced in the order of the values, without overlapping.
+	var pairs []string
+	for _, value := range values {
+		key := keys[value][0]
+		pairs = append(pairs, value, "{{"+key+"}}")
+		if len(keys[value]) > 1 {
+			log.Printf("warning: %q is the value of %s, using \"%s\"", value, strings.Join(keys[value], ", "), key)
+		}
+		log.Printf("found %d occurrences of the value of \"%s\"", strings.Count(text, value), key)
+	}
+
+	fmt.Print(strings.NewReplacer(pairs...).Replace(text))
+}
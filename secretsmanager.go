@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// Scheme of -output destinations which are AWS Secrets Manager secrets, followed by their name or ARN.
+const secretsManagerScheme = "secretsmanager://"
+
+// Stores the output as a new version of the AWS Secrets Manager secret, creating it if it does not exist,
+// so that services only reading from AWS Secrets Manager can use values from the table.
+// No version is created if the current one already holds the output.
+// Ex.: dynsubst -output secretsmanager://prod/app/config app-settings config.json
+func writeSecret(dst, output string) error {
+	id := strings.TrimPrefix(dst, secretsManagerScheme)
+	svc := secretsmanager.New(sess)
+
+	current, err := svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+		_, err = svc.CreateSecret(&secretsmanager.CreateSecretInput{
+			Name:         aws.String(id),
+			SecretString: aws.String(output),
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(current.SecretString) == output {
+		return nil
+	}
+
+	_, err = svc.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(id),
+		SecretString: aws.String(output),
+	})
+
+	return err
+}
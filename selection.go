@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// Patterns supplied with -only or -ignore, following the syntax of path.Match.
+type patternFlag []string
+
+func (p *patternFlag) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternFlag) Set(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	*p = append(*p, pattern)
+
+	return nil
+}
+
+var (
+	// Patterns of the keys of the placeholders to replace, when supplied.
+	onlyKeys patternFlag
+	// Patterns of the keys of the placeholders to leave intact.
+	ignoredKeys patternFlag
+)
+
+// Reports whether the placeholders of the key must be replaced, according to -only and -ignore.
+// Keys are matched relative to the prefix and without their version, as with JOIN patterns.
+// Ex.: with "-only 'app/*' -ignore 'app/legacy/*'", "{{app/port}}" is replaced, while "{{net/cidr}}" is left intact.
+func selected(key string) bool {
+	// Metadata and item fields are not keys of the table.
+	if strings.HasPrefix(key, "@") || strings.HasPrefix(key, ".") {
+		return true
+	}
+	key, _ = splitVersion(key)
+	for _, pattern := range ignoredKeys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return false
+		}
+	}
+	if len(onlyKeys) == 0 {
+		return true
+	}
+	for _, pattern := range onlyKeys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestSelected(t *testing.T) {
+	savedOnly, savedIgnored := onlyKeys, ignoredKeys
+	t.Cleanup(func() { onlyKeys, ignoredKeys = savedOnly, savedIgnored })
+
+	tests := []struct {
+		name          string
+		only, ignored patternFlag
+		key           string
+		want          bool
+	}{
+		{"no patterns", nil, nil, "net/cidr", true},
+		{"only matching", patternFlag{"app/*"}, nil, "app/port", true},
+		{"only not matching", patternFlag{"app/*"}, nil, "net/cidr", false},
+		{"only several", patternFlag{"app/*", "net/*"}, nil, "net/cidr", true},
+		{"ignored", nil, patternFlag{"app/legacy/*"}, "app/legacy/port", false},
+		{"ignored over only", patternFlag{"app/*", "app/legacy/*"}, patternFlag{"app/legacy/*"}, "app/legacy/port", false},
+		{"version", patternFlag{"app/*"}, nil, "app/port@3", true},
+		{"metadata", patternFlag{"app/*"}, nil, "@Table", true},
+		{"item field", patternFlag{"app/*"}, nil, ".Value", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onlyKeys, ignoredKeys = tt.only, tt.ignored
+			if got := selected(tt.key); got != tt.want {
+				t.Errorf("selected(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// Attribute holding the signature of an item, as binary or base64-encoded string.
+const signatureAttr = "Signature"
+
+var (
+	// AWS KMS key supplied with -verify, whose signatures every value must carry.
+	verifyKey string
+	// Algorithm of the signatures, supplied with -signing-algorithm.
+	signingAlgorithm string
+)
+
+// Returns the digest signed for an item: the SHA-256 digest of its key and its value separated by a NUL byte,
+// so that values cannot be moved between keys without invalidating their signatures.
+// Values can be signed with the AWS CLI:
+//
+//	printf 'Key\0value' | openssl dgst -sha256 -binary > digest
+//	aws kms sign --key-id alias/dynsubst --message fileb://digest --message-type DIGEST \
+//		--signing-algorithm RSASSA_PSS_SHA_256 --query Signature --output text
+func signedDigest(key, value string) []byte {
+	digest := sha256.Sum256([]byte(key + "\x00" + value))
+	return digest[:]
+}
+
+// Verifies the signature of the item with the key supplied with -verify, if any,
+// refusing items without a valid signature.
+func verifySignature(attrs map[string]*dynamodb.AttributeValue) error {
+	if verifyKey == "" {
+		return nil
+	}
+
+	key := aws.StringValue(attrs["Key"].S)
+	sig := attrs[signatureAttr]
+	var signature []byte
+	switch {
+	case sig == nil:
+		return fmt.Errorf("refusing unsigned value of \"%s\"", key)
+	case sig.B != nil:
+		signature = sig.B
+	case sig.S != nil:
+		decoded, err := base64.StdEncoding.DecodeString(*sig.S)
+		if err != nil {
+			return fmt.Errorf("invalid signature of \"%s\": %w", key, err)
+		}
+		signature = decoded
+	}
+
+	svc := kms.New(sess, regionConfig())
+	resp, err := svc.Verify(&kms.VerifyInput{
+		KeyId:            aws.String(verifyKey),
+		Message:          signedDigest(key, aws.StringValue(attrs["Value"].S)),
+		MessageType:      aws.String(kms.MessageTypeDigest),
+		Signature:        signature,
+		SigningAlgorithm: aws.String(signingAlgorithm),
+	})
+	// Invalid signatures are usually reported as errors rather than as SignatureValid being false.
+	if err != nil {
+		return fmt.Errorf("refusing value of \"%s\" with invalid signature: %w", key, err)
+	}
+	if !aws.BoolValue(resp.SignatureValid) {
+		return fmt.Errorf("refusing value of \"%s\" with invalid signature", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Replaces errors caused by expired AWS IAM Identity Center (SSO) sessions, which are otherwise
+// reported as generic credential failures, by instructions on how to renew them.
+// It must run after requests are signed, as that is when credentials are retrieved.
+func ssoErrorHandler(r *request.Request) {
+	if err, ok := r.Error.(awserr.Error); ok && err.Code() == ssocreds.ErrCodeSSOProviderInvalidToken {
+		p := currentProfile()
+		r.Error = fmt.Errorf("the AWS SSO session of profile \"%s\" has expired or was never started, "+
+			"run \"aws sso login --profile %s\" to start a new one", p, p)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Marker preceding the hash in stamps.
+const stampMarker = "dynsubst-stamp: sha256:"
+
+var (
+	// Comment prefix of the stamp added to rendered files, which are not stamped when empty.
+	stampPrefix string
+	// Placeholders resolved since the last stamp, each followed by its value.
+	stampInputs []string
+)
+
+// Records a resolved placeholder as an input of the stamp of the file being rendered.
+func recordStampInput(input, value string) {
+	if stampPrefix != "" {
+		stampInputs = append(stampInputs, input, value)
+	}
+}
+
+// Returns the output of the template with a comment stamping it with a hash of the template
+// and of the values of its placeholders, after the shebang if any, when running with -stamp.
+// Also reports whether the destination, if any, is already stamped with the same hash,
+// in which case it is up to date and does not need to be written.
+// Ex.: with "-stamp '#'", "# dynsubst-stamp: sha256:4f9c..." is added as the first line.
+func stampOutput(dst, text, output string) (string, bool) {
+	if stampPrefix == "" {
+		return output, false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(text))
+	for _, input := range stampInputs {
+		h.Write([]byte{0})
+		h.Write([]byte(input))
+	}
+	stampInputs = nil
+	stamp := fmt.Sprintf("%s %s%x", stampPrefix, stampMarker, h.Sum(nil))
+
+	if strings.HasPrefix(output, "#!") {
+		i := strings.Index(output, "\n") + 1
+		if i == 0 {
+			output += "\n"
+			i = len(output)
+		}
+		output = output[:i] + stamp + "\n" + output[i:]
+	} else {
+		output = stamp + "\n" + output
+	}
+
+	return output, dst != "" && currentStamp(dst) == stamp
+}
+
+// Returns the stamp of the file, looked up in its first two lines, if any.
+// Files compressed with gzip, as written by apply manifests, are decompressed first.
+func currentStamp(name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return ""
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	scanner := bufio.NewScanner(r)
+	for i := 0; i < 2 && scanner.Scan(); i++ {
+		if strings.Contains(scanner.Text(), stampMarker) {
+			return scanner.Text()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Namespace of the metrics emitted at the end of a run.
+const metricsNamespace = "dynsubst"
+
+var (
+	// Counters of the current run.
+	stats runStats
+	// Whether to print a summary of the run, supplied with -stats.
+	printStats bool
+	// Address of the StatsD server supplied with -statsd, as "host:port".
+	statsdAddr string
+	// Whether to print metrics in the CloudWatch embedded metric format, supplied with -emf.
+	emf bool
+)
+
+// Counters of a run.
+type runStats struct {
+	// Placeholders resolved successfully and unsuccessfully.
+	resolved, failed int
+	// Values retrieved from the prefetched snapshot instead of AWS DynamoDB.
+	cached int
+	// Values retrieved from the cache as they were fresh, or as AWS DynamoDB was unavailable.
+	local, stale int
+	// Values decrypted with AWS KMS.
+	decrypted int
+	// Requests sent to AWS, including failed ones.
+	requests int
+	// Read capacity units consumed by AWS DynamoDB requests.
+	capacity float64
+}
+
+// Returns a summary of the run, useful to tune prefetching and caching.
+func (s runStats) String() string {
+	return fmt.Sprintf("%d placeholders: %d resolved, %d failed, %d prefetched, %d from cache, %d stale, %d decrypted (KMS requests), "+
+		"%d AWS requests consuming %g read capacity units in %v",
+		s.resolved+s.failed, s.resolved, s.failed, s.cached, s.local, s.stale, s.decrypted, s.requests, s.capacity,
+		time.Since(started).Round(time.Millisecond))
+}
+
+// Adds the capacity consumed by AWS DynamoDB requests to the counters.
+func countCapacity(consumed ...*dynamodb.ConsumedCapacity) {
+	for _, c := range consumed {
+		if c != nil {
+			stats.capacity += aws.Float64Value(c.CapacityUnits)
+		}
+	}
+}
+
+// Counts a request sent to AWS.
+func countRequest(r *request.Request) {
+	stats.requests++
+}
+
+// Emits the metrics of the run to StatsD or as CloudWatch EMF, as requested with flags.
+// Failures are logged rather than returned, as they must not affect the render.
+func emitMetrics() {
+	duration := time.Since(started)
+	if statsdAddr != "" {
+		if err := sendStatsD(duration); err != nil {
+			log.Printf("warning: error sending metrics to StatsD: %v", err)
+		}
+	}
+	if emf {
+		if err := printEMF(duration); err != nil {
+			log.Printf("warning: error printing metrics: %v", err)
+		}
+	}
+}
+
+// Sends the metrics of the run to StatsD over UDP.
+func sendStatsD(duration time.Duration) error {
+	conn, err := net.Dial("udp", statsdAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(conn, "%[1]s.resolved:%[2]d|c\n%[1]s.failed:%[3]d|c\n%[1]s.duration:%[4]d|ms\n",
+		metricsNamespace, stats.resolved, stats.failed, duration.Milliseconds())
+	return err
+}
+
+// Prints the metrics of the run to standard error in the CloudWatch embedded metric format,
+// so that they are extracted from the logs by AWS Lambda or the CloudWatch agent.
+func printEMF(duration time.Duration) error {
+	type metric struct {
+		Name string
+		Unit string
+	}
+	doc := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+			"CloudWatchMetrics": []interface{}{
+				map[string]interface{}{
+					"Namespace":  metricsNamespace,
+					"Dimensions": [][]string{{"Table"}},
+					"Metrics": []metric{
+						{"Resolved", "Count"},
+						{"Failed", "Count"},
+						{"Duration", "Milliseconds"},
+						{"ConsumedReadCapacity", "Count"},
+					},
+				},
+			},
+		},
+		"Table":    table,
+		"Resolved": stats.resolved,
+		"Failed":   stats.failed,
+		"Duration": duration.Milliseconds(),
+		// Capacity units are fractional for eventually consistent reads.
+		"ConsumedReadCapacity": stats.capacity,
+	}
+
+	output, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stderr, string(output))
+	return err
+}
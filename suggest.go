@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Maximum amount of suggestions offered for a key which does not exist.
+const maxSuggestions = 3
+
+// Error of a key which does not exist in a table.
+// Suggestions are only looked for once the error is reported, as the table has to be scanned,
+// while keys are expected to be missing when looking them up in blocks or in several tables.
+type keyNotFoundError struct {
+	table, key string
+	// Session the key was looked up with, which may be for another account or region.
+	sess *session.Session
+	err  error
+}
+
+func (e *keyNotFoundError) Error() string {
+	return e.err.Error()
+}
+
+func (e *keyNotFoundError) Unwrap() error {
+	return e.err
+}
+
+// Returns the error with the keys closest to the one which does not exist, if that is what caused it.
+// Ex.: error querying for "DBPasword": 0 occurrences found (did you mean "DBPassword"?)
+func withSuggestions(err error) error {
+	var notFound *keyNotFoundError
+	if !errors.As(err, &notFound) {
+		return err
+	}
+
+	defer func(s *session.Session) { sess = s }(sess)
+	sess = notFound.sess
+	if suggestions := suggest(notFound.table, notFound.key); len(suggestions) > 0 {
+		return fmt.Errorf("%w (did you mean %s?)", err, strings.Join(suggestions, ", "))
+	}
+
+	return err
+}
+
+// Returns the quoted keys of the table closest to the one specified, closest first.
+// Suggestions are best effort: no suggestions are returned if the table cannot be listed.
+func suggest(table, key string) []string {
+	keys, err := listKeys(table)
+	if err != nil {
+		return nil
+	}
+
+	// Keys further away than this are unlikely to be what was meant.
+	threshold := len(key) / 3
+	if threshold < 2 {
+		threshold = 2
+	}
+
+	type candidate struct {
+		key      string
+		distance int
+	}
+	var candidates []candidate
+	for _, k := range keys {
+		if d := distance(strings.ToLower(key), strings.ToLower(k)); d <= threshold {
+			candidates = append(candidates, candidate{k, d})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, fmt.Sprintf("\"%s\"", candidates[i].key))
+	}
+
+	return suggestions
+}
+
+// Returns the Levenshtein distance between two strings.
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(t)]
+}
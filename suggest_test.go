@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"DBPassword", "DBPassword", 0},
+		{"DBPasword", "DBPassword", 1},
+		{"DBHost", "DBPort", 2},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFetchNotFound(t *testing.T) {
+	setValues(t, map[string]string{"Host": "db.example.com"})
+	snapshot[table]["Missing"] = nil
+
+	// Keys missing in blocks are expected, so the table is not scanned for suggestions.
+	output, err := render("{{#IF Missing}}debug{{/IF}}")
+	if err != nil || output != "" {
+		t.Fatalf("got %q and %v", output, err)
+	}
+
+	_, err = fetch(table, "Missing")
+	var notFound *keyNotFoundError
+	if !errors.Is(err, errNotFound) || !errors.As(err, &notFound) {
+		t.Fatalf("got error %v, want a key not found error", err)
+	}
+	if notFound.table != table || notFound.key != "Missing" {
+		t.Errorf("got table %q and key %q", notFound.table, notFound.key)
+	}
+}
+
+func TestWithSuggestionsOtherErrors(t *testing.T) {
+	err := errors.New("access denied")
+	if got := withSuggestions(err); got != err {
+		t.Errorf("got %v, want the error unchanged", got)
+	}
+}
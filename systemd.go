@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix of assignments in unit files, as opposed to environment files.
+const systemdEnvironment = "Environment="
+
+// Matches values which can be written in environment files without quotes.
+var systemdSafeRe = regexp.MustCompile(`^[\w@%+=:,./-]*$`)
+
+// Returns the environment file, as read with EnvironmentFile=, after replacing placeholders in values
+// and quoting them as systemd requires. Assignments in unit files ("Environment=KEY=value") are also
+// supported, in which case "%" is escaped as well, as it would otherwise start a specifier.
+// Placeholders in comments and names are left as is.
+func renderSystemdEnv(text string) (string, error) {
+	lines := strings.Split(text, "\n")
+	for n, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") || !strings.Contains(line, "{{") {
+			continue
+		}
+
+		var err error
+		if strings.HasPrefix(trimmed, systemdEnvironment) {
+			lines[n], err = systemdUnitAssignment(trimmed)
+		} else {
+			lines[n], err = systemdEnvAssignment(line)
+		}
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", n+1, err)
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Returns an assignment of an environment file after replacing the placeholders of its value.
+// Values which are not safe unquoted are double-quoted, escaping characters that systemd would interpret.
+func systemdEnvAssignment(line string) (string, error) {
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return line, nil
+	}
+	value, err := render(systemdUnquote(strings.TrimSpace(line[i+1:])))
+	if err != nil {
+		return "", err
+	}
+	if !systemdSafeRe.MatchString(value) {
+		value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(value) + `"`
+	}
+
+	return line[:i+1] + value, nil
+}
+
+// Returns a single assignment of a unit file ("Environment=KEY=value") after replacing the placeholders of its value.
+// The assignment is double-quoted, escaping quotes, backslashes, line breaks and specifiers.
+func systemdUnitAssignment(line string) (string, error) {
+	assignment := systemdUnquote(strings.TrimPrefix(line, systemdEnvironment))
+	i := strings.Index(assignment, "=")
+	if i < 0 {
+		return line, nil
+	}
+	value, err := render(assignment[i+1:])
+	if err != nil {
+		return "", err
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "%", "%%").Replace(value)
+
+	return fmt.Sprintf(`%s"%s=%s"`, systemdEnvironment, assignment[:i], value), nil
+}
+
+// Returns the value without the single or double quotes surrounding it, if any.
+func systemdUnquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestRenderSystemdEnv(t *testing.T) {
+	setValues(t, map[string]string{
+		"Host":     "db.example.com",
+		"Password": "p\"a$s`s\\",
+		"Greeting": "50% off\nnow",
+	})
+
+	testRender(t, renderSystemdEnv, []renderTest{
+		{"safe value", "DB_HOST={{Host}}", "DB_HOST=db.example.com"},
+		{"quoted value", "DB_PASSWORD={{Password}}", "DB_PASSWORD=\"p\\\"a\\$s\\`s\\\\\""},
+		{"already quoted", "DB_HOST=\"{{Host}}\"", "DB_HOST=db.example.com"},
+		{"comments", "# {{Host}}\n; {{Host}}", "# {{Host}}\n; {{Host}}"},
+		{"unit assignment", "Environment=DB_HOST={{Host}}", "Environment=\"DB_HOST=db.example.com\""},
+		{"unit specifiers", "Environment=\"GREETING={{Greeting}}\"", "Environment=\"GREETING=50%% off\\nnow\""},
+	})
+}
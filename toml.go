@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns the TOML document after replacing placeholders, escaping values according to where they are found:
+// inside basic strings values are escaped, inside literal strings values cannot contain quotes,
+// and outside strings values are emitted as basic strings unless running with -native.
+// Blocks inside strings are rendered and escaped as values, while blocks outside strings are expanded as TOML.
+// Placeholders inside comments are left as is.
+func renderTOML(text string) (string, error) {
+	var b strings.Builder
+	var quote string // Delimiter of the string being scanned, if any.
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case quote == "" && rest[0] == '#':
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			b.WriteString(rest[:end])
+			i += end
+			continue
+		case quote == "" && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, "'''")):
+			quote = rest[:3]
+			b.WriteString(quote)
+			i += len(quote)
+			continue
+		case quote == "" && (rest[0] == '"' || rest[0] == '\''):
+			quote = rest[:1]
+			b.WriteString(quote)
+			i += len(quote)
+			continue
+		case quote != "" && strings.HasPrefix(rest, quote):
+			b.WriteString(quote)
+			i += len(quote)
+			quote = ""
+			continue
+		case quote != "" && quote[0] == '"' && rest[0] == '\\' && len(rest) > 1:
+			// Escaped characters cannot close basic strings.
+			b.WriteString(rest[:2])
+			i += 2
+			continue
+		case strings.HasPrefix(rest, "{{"):
+			if loc := blockRe.FindStringSubmatchIndex(rest); loc != nil && loc[0] == 0 {
+				output, remaining, err := renderTOMLBlock(rest, loc, quote)
+				if err != nil {
+					return "", err
+				}
+				b.WriteString(output)
+				i = len(text) - len(remaining)
+				continue
+			}
+			if loc := placeholderRe.FindStringIndex(rest); loc != nil && loc[0] == 0 {
+				value, err := resolve(rest[:loc[1]])
+				if err != nil {
+					return "", err
+				}
+				value, err = tomlValue(value, quote)
+				if err != nil {
+					return "", fmt.Errorf("error replacing \"%s\": %w", rest[:loc[1]], err)
+				}
+				b.WriteString(value)
+				i += loc[1]
+				continue
+			}
+		}
+
+		if len(quote) == 1 && rest[0] == '\n' {
+			return "", fmt.Errorf("unterminated TOML string")
+		}
+		b.WriteByte(rest[0])
+		i++
+	}
+
+	return b.String(), nil
+}
+
+// Returns the expansion of the block whose opening tag is located at loc, along with the text following it.
+// Inside strings the expansion is escaped as a value, outside them it is rendered as a TOML document.
+func renderTOMLBlock(text string, loc []int, quote string) (output, rest string, err error) {
+	if quote == "" {
+		return renderBlock(text, loc, renderTOML)
+	}
+
+	output, rest, err = renderBlock(text, loc, render)
+	if err != nil {
+		return "", "", err
+	}
+	output, err = tomlValue(output, quote)
+	if err != nil {
+		return "", "", fmt.Errorf("error replacing \"%s\": %w", text[:len(text)-len(rest)], err)
+	}
+
+	return output, rest, nil
+}
+
+// Returns the value escaped for the context of a TOML document delimited by the quote.
+func tomlValue(value, quote string) (string, error) {
+	switch quote {
+	case "":
+		if native {
+			return value, nil
+		}
+		return `"` + tomlEscape(value, false) + `"`, nil
+	case `"`:
+		return tomlEscape(value, false), nil
+	case `"""`:
+		return tomlEscape(value, true), nil
+	case "'":
+		if strings.ContainsAny(value, "'\n") {
+			return "", fmt.Errorf("value cannot be embedded in a TOML literal string")
+		}
+	case "'''":
+		if strings.Contains(value, "'''") {
+			return "", fmt.Errorf("value cannot be embedded in a TOML multi-line literal string")
+		}
+	}
+
+	return value, nil
+}
+
+// Returns the value escaped for a TOML basic string, keeping newlines in multi-line ones.
+func tomlEscape(value string, multiline bool) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\n' && multiline:
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
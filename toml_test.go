@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRenderTOML(t *testing.T) {
+	setValues(t, map[string]string{
+		"Host":        "db.example.com",
+		"Password":    `p\a"ss`,
+		"Environment": "prod",
+		"Debug":       "true",
+	})
+
+	testRender(t, renderTOML, []renderTest{
+		{"bare value", "host = {{Host}}", `host = "db.example.com"`},
+		{"basic string", `password = "{{Password}}"`, `password = "p\\a\"ss"`},
+		{"literal string", "host = '{{Host}}'", "host = 'db.example.com'"},
+		{"comment", "# {{Host}}", "# {{Host}}"},
+		{"block outside strings", "{{#IF Debug}}password = {{Password}}\n{{/IF}}", "password = \"p\\\\a\\\"ss\"\n"},
+		{"block not matching", "{{#IFEQ Environment dev}}debug = true\n{{/IF}}host = {{Host}}", `host = "db.example.com"`},
+		{"block inside string", `password = "{{#IFEQ Environment prod}}{{Password}}{{/IF}}"`, `password = "p\\a\"ss"`},
+	})
+}
+
+func TestRenderTOMLLiteralString(t *testing.T) {
+	setValues(t, map[string]string{"Password": "p'ss"})
+
+	if _, err := renderTOML("password = '{{Password}}'"); err == nil {
+		t.Error("expected an error for a quote inside a literal string")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// Endpoint of the OTLP collector supplied with -otlp-endpoint, as "host:port".
+	otlpEndpoint string
+	// Provider exporting spans, when tracing is enabled.
+	tracerProvider *sdktrace.TracerProvider
+	// Spans are only recorded once a provider is registered.
+	tracer = otel.Tracer("github.com/gguillemas/dynsubst")
+	// Context of the span being recorded, which is the parent of the next one.
+	traceCtx = context.Background()
+)
+
+// Registers a provider exporting spans to the endpoint supplied with -otlp-endpoint, if any.
+func startTracing() error {
+	if otlpEndpoint == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpoint(otlpEndpoint))
+	if err != nil {
+		return err
+	}
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "dynsubst"))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	return nil
+}
+
+// Exports the spans recorded so far, as the batcher would otherwise drop them on exit.
+func stopTracing() {
+	if tracerProvider == nil {
+		return
+	}
+	if err := tracerProvider.Shutdown(context.Background()); err != nil {
+		log.Printf("warning: error exporting spans: %v", err)
+	}
+}
+
+// Starts a span as a child of the current one, which it replaces until the returned function is called
+// with the outcome of the operation.
+func startSpan(name string, attrs ...attribute.KeyValue) func(error) {
+	parent := traceCtx
+	ctx, span := tracer.Start(parent, name, trace.WithAttributes(attrs...))
+	traceCtx = ctx
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		traceCtx = parent
+	}
+}
+
+// Starts a span for an AWS request as a child of the current one.
+func startRequestSpan(r *request.Request) {
+	_, span := tracer.Start(traceCtx, r.ClientInfo.ServiceName+"."+r.Operation.Name, trace.WithSpanKind(trace.SpanKindClient))
+	r.SetContext(trace.ContextWithSpan(r.Context(), span))
+}
+
+// Ends the span of an AWS request, recording its request ID and attempts.
+func endRequestSpan(r *request.Request) {
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(attribute.String("aws.request_id", r.RequestID), attribute.Int("aws.attempts", r.RetryCount+1))
+	if r.Error != nil {
+		span.RecordError(r.Error)
+		span.SetStatus(codes.Error, r.Error.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	// Proxy for AWS requests supplied with -proxy, taking precedence over HTTPS_PROXY and HTTP_PROXY.
+	proxyURL string
+	// Timeouts supplied with -connect-timeout and -timeout, and interval supplied with -keep-alive.
+	connectTimeout, requestTimeout, keepAlive time.Duration
+	// File of PEM certificates supplied with -ca-bundle, trusted instead of the system ones.
+	caBundle string
+	// Minimum TLS version supplied with -tls-min-version.
+	tlsMinVersion string
+)
+
+// TLS versions that can be supplied with -tls-min-version.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Returns the HTTP client used for AWS requests, as configured with flags.
+// Backends other than AWS should use it as well, so that they honor the same network settings.
+func httpClient() (*http.Client, error) {
+	tlsConfig, err := tlsClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL \"%s\"", proxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	transport := &http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: keepAlive,
+		}).DialContext,
+		TLSHandshakeTimeout: connectTimeout,
+		// Negative intervals disable keep-alives, as with net.Dialer.
+		DisableKeepAlives: keepAlive < 0,
+		MaxIdleConns:      100,
+		IdleConnTimeout:   90 * time.Second,
+	}
+
+	return &http.Client{Transport: transport, Timeout: requestTimeout}, nil
+}
+
+// Returns the TLS configuration for the CA bundle and minimum version supplied with flags.
+func tlsClientConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if tlsMinVersion != "" {
+		version, ok := tlsVersions[tlsMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS version \"%s\": expected \"1.0\", \"1.1\", \"1.2\" or \"1.3\"", tlsMinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if caBundle != "" {
+		pem, err := ioutil.ReadFile(caBundle)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in \"%s\"", caBundle)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// TTL attribute of every table checked so far, empty when TTL is disabled.
+var ttlAttributes = map[string]string{}
+
+// Reports whether the item has expired according to the TTL attribute of the table.
+// AWS DynamoDB may take a while to delete expired items, so they must be treated as missing.
+func expired(table string, attrs map[string]*dynamodb.AttributeValue) bool {
+	attr := ttlAttribute(table)
+	if attr == "" || attrs[attr] == nil || attrs[attr].N == nil {
+		return false
+	}
+
+	epoch, err := strconv.ParseInt(aws.StringValue(attrs[attr].N), 10, 64)
+	if err != nil {
+		return false
+	}
+	if time.Unix(epoch, 0).After(time.Now()) {
+		return false
+	}
+
+	log.Printf("warning: ignoring expired item \"%s\" in \"%s\"", aws.StringValue(attrs["Key"].S), table)
+	return true
+}
+
+// Returns the TTL attribute of the table, either as specified with -ttl-attr or as configured in the table.
+// Tables whose TTL configuration cannot be retrieved are considered to have TTL disabled.
+func ttlAttribute(table string) string {
+	if ttlAttr != "" {
+		return ttlAttr
+	}
+	if attr, ok := ttlAttributes[cacheKey(table)]; ok {
+		return attr
+	}
+
+	var attr string
+	svc := dynamodb.New(sess, regionConfig())
+	resp, err := svc.DescribeTimeToLive(&dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(table),
+	})
+	if err == nil && resp.TimeToLiveDescription != nil && aws.StringValue(resp.TimeToLiveDescription.TimeToLiveStatus) == dynamodb.TimeToLiveStatusEnabled {
+		attr = aws.StringValue(resp.TimeToLiveDescription.AttributeName)
+	}
+	ttlAttributes[cacheKey(table)] = attr
+
+	return attr
+}
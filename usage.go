@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A reference to a key found in a template.
+type reference struct {
+	File      string   `json:"file"`
+	Line      int      `json:"line"`
+	Table     string   `json:"table"`
+	Key       string   `json:"key"`
+	Modifiers []string `json:"modifiers"`
+}
+
+// Returns the references to keys in the template read from the file, in order of appearance.
+// Keys of blocks are included, while metadata, item fields and values from other sources are not.
+func references(file, text string) []reference {
+	var refs []reference
+	add := func(offset int, input string) {
+		p, err := parsePlaceholder(input)
+		if err != nil || p.skipped != "" || p.source != "" {
+			return
+		}
+		if strings.HasPrefix(p.key, "@") || strings.HasPrefix(p.key, ".") {
+			return
+		}
+		ref := reference{
+			File:      file,
+			Line:      strings.Count(text[:offset], "\n") + 1,
+			Table:     p.table,
+			Key:       p.key,
+			Modifiers: []string{},
+		}
+		for _, m := range p.modifiers {
+			ref.Modifiers = append(ref.Modifiers, m.name)
+		}
+		refs = append(refs, ref)
+	}
+
+	for _, loc := range placeholderRe.FindAllStringIndex(text, -1) {
+		input := text[loc[0]:loc[1]]
+		if m := blockRe.FindStringSubmatch(input); m != nil {
+			// Blocks without a key are reported when rendering them.
+			if fields := strings.Fields(m[2]); len(fields) > 0 && (m[1] == blockIf || m[1] == blockIfEq) {
+				add(loc[0], "{{"+fields[0]+"}}")
+			}
+			continue
+		}
+		add(loc[0], input)
+	}
+
+	return refs
+}
+
+// Reports which templates in the supplied files or directories reference which keys,
+// to find unused keys or the templates affected by changing one.
+// Ex.: dynsubst usage -format csv ./configs
+func runUsage(args []string) {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	outputFormat := fs.String("format", "csv", "specify output format: \"csv\" or \"json\"")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		log.Fatal("usage: missing files or directories")
+	}
+
+	refs := []reference{}
+	for _, root := range fs.Args() {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			input, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			refs = append(refs, references(path, string(input))...)
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("usage: %v", err)
+		}
+	}
+
+	switch *outputFormat {
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"file", "line", "table", "key", "modifiers"})
+		for _, ref := range refs {
+			w.Write([]string{ref.File, strconv.Itoa(ref.Line), ref.Table, ref.Key, strings.Join(ref.Modifiers, ":")})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Fatalf("usage: %v", err)
+		}
+	case "json":
+		output, err := json.MarshalIndent(refs, "", "  ")
+		if err != nil {
+			log.Fatalf("usage: %v", err)
+		}
+		os.Stdout.Write(append(output, '\n'))
+	default:
+		log.Fatalf("usage: unknown format \"%s\"", *outputFormat)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	setValues(t, nil)
+
+	text := "host: {{Host}}\n" +
+		"password: {{B64:DECRYPT:TABLE=credentials:Password}}\n" +
+		"{{#IFEQ Environment prod}}debug: false{{/IF}}\n" +
+		"{{#EACH app/hosts/*}}- {{.Value}}\n{{/EACH}}" +
+		"skipped: {{SKIP:Host}}\n" +
+		"included: {{INCLUDE:header.txt}}\n" +
+		"table: {{@Table}}\n" +
+		"empty: {{#IF   }}"
+	want := []reference{
+		{File: "config.yaml", Line: 1, Table: "test-settings", Key: "Host", Modifiers: []string{}},
+		{File: "config.yaml", Line: 2, Table: "credentials", Key: "Password", Modifiers: []string{modB64, modDecrypt}},
+		{File: "config.yaml", Line: 3, Table: "test-settings", Key: "Environment", Modifiers: []string{}},
+	}
+
+	if got := references("config.yaml", text); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
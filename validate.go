@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Value replacing placeholders in templates checked with -validate-template,
+// which is valid as is in JSON, YAML and XML documents, both inside and outside strings.
+const validationStub = "0"
+
+// Check of templates before rendering them, supplied with -validate-template.
+var templateCheck string
+
+// Checks the template with the check supplied with -validate-template, after replacing its placeholders
+// with a stub value and removing the tags of its blocks, so that malformed templates fail before any AWS request.
+// Checks are either "json", "yaml" or "xml", which parse the template, or a shell command which must succeed,
+// where "%s" is replaced with the path of a temporary file containing the template.
+// Ex.: -validate-template yaml, or -validate-template 'promtool check config %s'
+func validateTemplate(text string) error {
+	if templateCheck == "" {
+		return nil
+	}
+
+	stubbed := placeholderRe.ReplaceAllStringFunc(text, func(input string) string {
+		if blockRe.MatchString(input) {
+			return ""
+		}
+		return validationStub
+	})
+
+	var err error
+	switch templateCheck {
+	case formatJSON:
+		dec := json.NewDecoder(strings.NewReader(stubbed))
+		for err == nil {
+			var v interface{}
+			err = dec.Decode(&v)
+		}
+	case formatYAML:
+		dec := yaml.NewDecoder(strings.NewReader(stubbed))
+		for err == nil {
+			var v yaml.Node
+			err = dec.Decode(&v)
+		}
+	case formatXML:
+		dec := xml.NewDecoder(strings.NewReader(stubbed))
+		for err == nil {
+			_, err = dec.Token()
+		}
+	default:
+		return validateWithCommand(stubbed)
+	}
+	if !errors.Is(err, io.EOF) {
+		return fmt.Errorf("template is not valid %s: %w", strings.ToUpper(templateCheck), err)
+	}
+
+	return nil
+}
+
+// Runs the shell command supplied with -validate-template on a temporary file containing the stubbed template.
+func validateWithCommand(stubbed string) error {
+	f, err := ioutil.TempFile("", "dynsubst-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(stubbed)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	quoted, _ := shellQuote(f.Name(), "")
+	cmd := exec.Command("sh", "-c", strings.ReplaceAll(templateCheck, "%s", quoted))
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("template validation with \"%s\" failed: %v: %s", templateCheck, err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name, check, text string
+		wantErr           bool
+	}{
+		{"no check", "", "{{{", false},
+		{"json", formatJSON, `{"host": "{{Host}}", "port": {{Port}}}`, false},
+		{"invalid json", formatJSON, `{"host": "{{Host}}",}`, true},
+		{"json blocks", formatJSON, `[{{#EACH app/*}}"{{.Value}}"{{/EACH}}]`, false},
+		{"yaml", formatYAML, "host: {{Host}}\nport: {{Port}}\n", false},
+		{"invalid yaml", formatYAML, "host: [{{Host}}\n", true},
+		{"xml", formatXML, `<db host="{{Host}}">{{Port}}</db>`, false},
+		{"invalid xml", formatXML, "<db>{{Host}}</dv>", true},
+		{"command", "grep -q '^port: 0$' %s", "port: {{Port}}\n", false},
+		{"failing command", "grep -q '^host' %s", "port: {{Port}}\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, "validate-template", tt.check)
+			err := validateTemplate(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Whether to log placeholders as they are resolved (-v), and AWS requests as well (-vv).
+var verbose, veryVerbose bool
+
+// Adds the handlers shared by every session.
+func addHandlers(s *session.Session) {
+	s.Handlers.Sign.PushBack(ssoErrorHandler)
+	s.Handlers.Complete.PushBack(countRequest)
+	if veryVerbose {
+		s.Handlers.Complete.PushBack(logRequest)
+	}
+	if otlpEndpoint != "" {
+		s.Handlers.Validate.PushFront(startRequestSpan)
+		s.Handlers.Complete.PushBack(endRequestSpan)
+	}
+}
+
+// Logs the outcome of an AWS request, including its request ID so that failures can be
+// correlated with AWS CloudTrail entries and support cases.
+func logRequest(r *request.Request) {
+	var status int
+	if r.HTTPResponse != nil {
+		status = r.HTTPResponse.StatusCode
+	}
+	if r.Error != nil {
+		log.Printf("%s %s: status %d, request ID %s, %d attempts: %v",
+			r.ClientInfo.ServiceName, r.Operation.Name, status, r.RequestID, r.RetryCount+1, r.Error)
+		return
+	}
+	log.Printf("%s %s: status %d, request ID %s, %d attempts",
+		r.ClientInfo.ServiceName, r.Operation.Name, status, r.RequestID, r.RetryCount+1)
+}
+
+// Logs the placeholder being resolved, without its value.
+func logPlaceholder(input string) {
+	if verbose || veryVerbose {
+		log.Printf("resolving %s", input)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Populates the cache supplied with -cache with every key of the table starting with a prefix,
+// so that renders within -cache-ttl do not need to call AWS DynamoDB.
+// Ex.: dynsubst -cache ~/.dynsubst/cache.json warm app-settings -prefix app/
+func runWarm(args []string) {
+	if cacheFile == "" {
+		log.Fatal("warm: -cache is not set")
+	}
+	fs := flag.NewFlagSet("warm", flag.ExitOnError)
+	keyPrefix := fs.String("prefix", "", "only cache keys starting with the prefix")
+	if len(args) < 1 {
+		log.Fatal("warm: missing table")
+	}
+	table = args[0]
+	fs.Parse(args[1:])
+
+	// Items can only be cached by their key when there is a single one for each.
+	desc, err := describeTable(table)
+	if err != nil {
+		log.Fatalf("warm: %v", err)
+	}
+	if len(desc.KeySchema) > 1 {
+		log.Fatalf("warm: cannot cache \"%s\" as it has a sort key", table)
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(table),
+	}
+	if *keyPrefix != "" {
+		scanInput.FilterExpression = aws.String("begins_with(#k, :prefix)")
+		scanInput.ExpressionAttributeNames = map[string]*string{"#k": aws.String("Key")}
+		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":prefix": {S: keyPrefix},
+		}
+	}
+
+	t := cacheKey(table)
+	if cache[t] == nil {
+		cache[t] = make(map[string]cacheEntry)
+	}
+	now := time.Now().UTC()
+	var count int
+	err = scanItems(scanInput, func(attrs map[string]*dynamodb.AttributeValue) error {
+		if attrs["Key"] != nil && attrs["Value"] != nil && !expired(table, attrs) {
+			if err := checkItem(attrs); err != nil {
+				return err
+			}
+			cache[t][aws.StringValue(attrs["Key"].S)] = cacheEntry{Value: aws.StringValue(attrs["Value"].S), Updated: now}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("warm: %v", err)
+	}
+
+	cacheModified = true
+	if err := saveCache(); err != nil {
+		log.Fatalf("warm: %v", err)
+	}
+	fmt.Printf("cached %d keys from \"%s\" (%g read capacity units)\n", count, table, stats.capacity)
+}
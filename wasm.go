@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WebAssembly plugins are modules in the plugin directory with the ".wasm" extension,
+// named after the modifier they implement in lowercase. They run sandboxed, without access
+// to the file system or the network, and must export the following functions:
+//
+//	alloc(size i32) i32
+//	modify(valuePtr, valueLen, argPtr, argLen i32) i64
+//
+// The result of modify packs the location of the output in linear memory: its pointer in bits 32 to 62
+// and its length in bits 0 to 31. When bit 63 is set, the output is an error message instead.
+// Plugins exporting free(ptr, size i32) or deallocate(ptr, size i32) have the input and output released
+// once the output has been read.
+const wasmErrorBit = 1 << 63
+
+// Maximum duration of a single call to a WebAssembly plugin, after which it is aborted.
+const wasmTimeout = 10 * time.Second
+
+var (
+	// Runtime shared by every WebAssembly plugin, created when loading the first one.
+	wasmRuntime wazero.Runtime
+	// Instantiated WebAssembly plugins, indexed by path.
+	wasmModules = map[string]api.Module{}
+)
+
+// Returns a modifier running the WebAssembly plugin at the path.
+func wasmModifier(path string) func(value, arg string) (string, error) {
+	return func(value, arg string) (string, error) {
+		module, err := wasmModule(path)
+		if err != nil {
+			return "", err
+		}
+		output, err := callWasm(module, value, arg)
+		if module.IsClosed() {
+			// Modules are closed when a call is aborted, so they are instantiated again on next use.
+			delete(wasmModules, path)
+		}
+		return output, err
+	}
+}
+
+// Returns the module of the WebAssembly plugin at the path, instantiating it on first use.
+func wasmModule(path string) (api.Module, error) {
+	if module, ok := wasmModules[path]; ok {
+		return module, nil
+	}
+
+	ctx := context.Background()
+	if wasmRuntime == nil {
+		// Calls are aborted once their context is done, so that plugins cannot loop forever.
+		wasmRuntime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+		// Modules compiled for WASI need its imports, which expose nothing of the host by default.
+		wasi_snapshot_preview1.MustInstantiate(ctx, wasmRuntime)
+	}
+
+	binary, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := wasmRuntime.CompileModule(ctx, binary)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	module, err := wasmRuntime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(path))
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	wasmModules[path] = module
+
+	return module, nil
+}
+
+// Calls the modify function of the module and returns its output.
+func callWasm(module api.Module, value, arg string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wasmTimeout)
+	defer cancel()
+
+	alloc, modify := module.ExportedFunction("alloc"), module.ExportedFunction("modify")
+	if alloc == nil || modify == nil {
+		return "", fmt.Errorf("plugin does not export alloc and modify")
+	}
+	memory := module.Memory()
+	if memory == nil {
+		return "", fmt.Errorf("plugin does not export its memory")
+	}
+	free := module.ExportedFunction("free")
+	if free == nil {
+		free = module.ExportedFunction("deallocate")
+	}
+
+	params := make([]uint64, 0, 4)
+	for _, s := range []string{value, arg} {
+		results, err := alloc.Call(ctx, uint64(len(s)))
+		if err != nil {
+			return "", err
+		}
+		ptr := uint32(results[0])
+		if !memory.Write(ptr, []byte(s)) {
+			return "", fmt.Errorf("plugin allocated memory out of range")
+		}
+		params = append(params, uint64(ptr), uint64(len(s)))
+	}
+	if free != nil {
+		defer func() {
+			for i := 0; i < len(params); i += 2 {
+				free.Call(ctx, params[i], params[i+1])
+			}
+		}()
+	}
+
+	results, err := modify.Call(ctx, params...)
+	if err != nil {
+		return "", err
+	}
+	packed := results[0]
+	ptr, length := uint32(packed>>32)&^(1<<31), uint32(packed)
+	view, ok := memory.Read(ptr, length)
+	if !ok {
+		return "", fmt.Errorf("plugin returned output out of range")
+	}
+	// The output is copied before being released, as reads return a view of the memory of the plugin.
+	output := string(view)
+	if free != nil {
+		params = append(params, uint64(ptr), uint64(length))
+	}
+	if packed&wasmErrorBit != 0 {
+		return "", fmt.Errorf("%s", output)
+	}
+
+	return output, nil
+}
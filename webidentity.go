@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// Environment variable pointing to the token used to assume a role with web identity,
+	// as set up by EKS for IAM roles for service accounts (IRSA).
+	envWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	// Time to wait for the token to be mounted, as it may appear after the container starts.
+	webIdentityTimeout = 30 * time.Second
+	// Interval between checks for the token.
+	webIdentityInterval = 500 * time.Millisecond
+)
+
+// Waits until the web identity token declared in the environment, if any, can be read.
+// Credentials are otherwise resolved by the SDK, which assumes the role with the token.
+func waitWebIdentityToken() error {
+	file := os.Getenv(envWebIdentityTokenFile)
+	if file == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(webIdentityTimeout)
+	for {
+		_, err := os.Stat(file)
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) || time.Now().After(deadline) {
+			return fmt.Errorf("error reading web identity token: %w", err)
+		}
+		time.Sleep(webIdentityInterval)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	xmlCommentStart = "<!--"
+	xmlCommentEnd   = "-->"
+	xmlCDATAStart   = "<![CDATA["
+	xmlCDATAEnd     = "]]>"
+)
+
+// Escapes characters with special meaning in XML text and attribute values.
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+
+// Returns the XML document after replacing placeholders with their values escaped as entities,
+// so that it remains well-formed whatever the values contain. Inside CDATA sections values are not escaped,
+// but sections are split where values contain their terminator. Placeholders in comments are left as is.
+func renderXML(text string) (string, error) {
+	var b strings.Builder
+	var cdata bool // Whether a CDATA section is being scanned.
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case !cdata && strings.HasPrefix(rest, xmlCommentStart):
+			end := strings.Index(rest, xmlCommentEnd)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(xmlCommentEnd)
+			}
+			b.WriteString(rest[:end])
+			i += end
+			continue
+		case !cdata && strings.HasPrefix(rest, xmlCDATAStart):
+			cdata = true
+			b.WriteString(xmlCDATAStart)
+			i += len(xmlCDATAStart)
+			continue
+		case cdata && strings.HasPrefix(rest, xmlCDATAEnd):
+			cdata = false
+			b.WriteString(xmlCDATAEnd)
+			i += len(xmlCDATAEnd)
+			continue
+		case strings.HasPrefix(rest, "{{"):
+			if loc := placeholderRe.FindStringIndex(rest); loc != nil && loc[0] == 0 {
+				value, err := resolve(rest[:loc[1]])
+				if err != nil {
+					return "", err
+				}
+				if cdata {
+					value = strings.ReplaceAll(value, xmlCDATAEnd, "]]"+xmlCDATAEnd+xmlCDATAStart+">")
+				} else {
+					value = xmlEscaper.Replace(value)
+				}
+				b.WriteString(value)
+				i += loc[1]
+				continue
+			}
+		}
+
+		b.WriteByte(rest[0])
+		i++
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestRenderXML(t *testing.T) {
+	setValues(t, map[string]string{
+		"Host":  "db.example.com",
+		"Query": `a < b && c > "d" 'e'`,
+		"Data":  "x]]>y",
+	})
+
+	testRender(t, renderXML, []renderTest{
+		{"text", "<host>{{Host}}</host>", "<host>db.example.com</host>"},
+		{"escaped text", "<query>{{Query}}</query>", "<query>a &lt; b &amp;&amp; c &gt; &quot;d&quot; &apos;e&apos;</query>"},
+		{"attribute", `<db query="{{Query}}"/>`, `<db query="a &lt; b &amp;&amp; c &gt; &quot;d&quot; &apos;e&apos;"/>`},
+		{"comment", "<!-- {{Host}} -->", "<!-- {{Host}} -->"},
+		{"cdata", "<![CDATA[{{Query}}]]>", `<![CDATA[a < b && c > "d" 'e']]>`},
+		{"cdata terminator", "<![CDATA[{{Data}}]]>", "<![CDATA[x]]]]><![CDATA[>y]]>"},
+	})
+}
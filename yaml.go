@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Returns the YAML documents after replacing placeholders inside their scalar values.
+// Comments are preserved, although the documents are re-emitted with the default indentation.
+func renderYAML(text string) (string, error) {
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := renderYAMLNode(&doc, nil); err != nil {
+			return "", err
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return "", err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// Renders the node reached by the steps from the root of the document.
+func renderYAMLNode(node *yaml.Node, steps []pathStep) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			if err := renderYAMLNode(n, steps); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			if err := renderYAMLNode(n, append(steps, pathStep{index: i})); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		if err := checkYAMLPlaceholderMapping(node); err != nil {
+			return err
+		}
+		// Keys are left as is.
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := checkYAMLPlaceholderMapping(node.Content[i-1]); err != nil {
+				return err
+			}
+			step := pathStep{key: node.Content[i-1].Value, index: -1}
+			if err := renderYAMLNode(node.Content[i], append(steps, step)); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if targeted(steps) {
+			return renderYAMLScalar(node)
+		}
+	}
+
+	return nil
+}
+
+// Fails if the node is a mapping parsed from an unquoted placeholder, as "{{Key}}" is a flow mapping
+// in YAML whose only key is another flow mapping, which would otherwise be left unreplaced and re-emitted broken.
+func checkYAMLPlaceholderMapping(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode || node.Style&yaml.FlowStyle == 0 || len(node.Content) != 2 {
+		return nil
+	}
+	key := node.Content[0]
+	if key.Kind != yaml.MappingNode || key.Style&yaml.FlowStyle == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("line %d: unquoted placeholder parsed as a mapping: quote the placeholder, as in \"{{Key}}\"", node.Line)
+}
+
+func renderYAMLScalar(node *yaml.Node) error {
+	if !strings.Contains(node.Value, "{{") {
+		return nil
+	}
+
+	if native && placeholderRe.FindString(node.Value) == node.Value {
+		value, err := resolve(node.Value)
+		if err != nil {
+			return err
+		}
+		var doc yaml.Node
+		if yaml.Unmarshal([]byte(value), &doc) == nil && len(doc.Content) == 1 {
+			replacement := doc.Content[0]
+			replacement.HeadComment, replacement.LineComment, replacement.FootComment = node.HeadComment, node.LineComment, node.FootComment
+			*node = *replacement
+			return nil
+		}
+		setYAMLString(node, value)
+		return nil
+	}
+
+	output, err := render(node.Value)
+	if err != nil {
+		return err
+	}
+	setYAMLString(node, output)
+
+	return nil
+}
+
+// Sets the value of a scalar node as a string, emitting multi-line values as literal block scalars.
+func setYAMLString(node *yaml.Node, value string) {
+	node.Value = value
+	node.Tag = "!!str"
+	if strings.Contains(value, "\n") {
+		node.Style = yaml.LiteralStyle
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderYAML(t *testing.T) {
+	setValues(t, map[string]string{
+		"Host": "db.example.com",
+		"Port": "5432",
+		"Cert": "line1\nline2",
+	})
+
+	testRender(t, renderYAML, []renderTest{
+		{"quoted", "host: \"{{Host}}\"\n", "host: \"db.example.com\"\n"},
+		{"inside string", "url: \"postgres://{{Host}}:{{Port}}\"\n", "url: \"postgres://db.example.com:5432\"\n"},
+		{"string type kept", "port: \"{{Port}}\"\n", "port: \"5432\"\n"},
+		{"multi-line", "cert: \"{{Cert}}\"\n", "cert: |-\n  line1\n  line2\n"},
+		{"comments kept", "# {{Host}}\nhost: \"{{Host}}\" # main\n", "# {{Host}}\nhost: \"db.example.com\" # main\n"},
+	})
+}
+
+func TestRenderYAMLUnquotedPlaceholder(t *testing.T) {
+	setValues(t, map[string]string{"Port": "5432"})
+
+	for _, input := range []string{
+		"port: {{Port}}\n",
+		"ports:\n  - {{Port}}\n",
+		"{{Port}}: port\n",
+	} {
+		_, err := renderYAML(input)
+		if err == nil || !strings.Contains(err.Error(), "quote the placeholder") {
+			t.Errorf("%q: got error %v, want one asking to quote the placeholder", input, err)
+		}
+	}
+}